@@ -0,0 +1,125 @@
+package eval
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+var (
+	errDivisionByZero = errors.New("division by zero")
+	errOutOfRange     = errors.New("result out of range")
+)
+
+// binary holds the words that replace the top two cells with a single
+// result. n1 is the second cell and n2 the top, so "n1 n2 -" is n1-n2.
+var binary = map[word.WordType]func(n1, n2 int) int{
+	word.ADD:      func(n1, n2 int) int { return n1 + n2 },
+	word.SUBTRACT: func(n1, n2 int) int { return n1 - n2 },
+	word.MULTIPLY: func(n1, n2 int) int { return n1 * n2 },
+	word.AND:      func(n1, n2 int) int { return n1 & n2 },
+	word.OR:       func(n1, n2 int) int { return n1 | n2 },
+	word.XOR:      func(n1, n2 int) int { return n1 ^ n2 },
+	word.LSHIFT:   func(n1, n2 int) int { return int(uint(n1) << uint(n2)) },
+	word.MIN:      func(n1, n2 int) int { return min(n1, n2) },
+	word.MAX:      func(n1, n2 int) int { return max(n1, n2) },
+	word.EQ:       func(n1, n2 int) int { return flag(n1 == n2) },
+	word.NOTEQ:    func(n1, n2 int) int { return flag(n1 != n2) },
+	word.LT:       func(n1, n2 int) int { return flag(n1 < n2) },
+	word.GT:       func(n1, n2 int) int { return flag(n1 > n2) },
+	word.LE:       func(n1, n2 int) int { return flag(n1 <= n2) },
+	word.GE:       func(n1, n2 int) int { return flag(n1 >= n2) },
+	word.ULT:      func(n1, n2 int) int { return flag(uint(n1) < uint(n2)) },
+	word.UGT:      func(n1, n2 int) int { return flag(uint(n1) > uint(n2)) },
+}
+
+// unary holds the words that replace the top cell with a single result.
+var unary = map[word.WordType]func(n int) int{
+	word.NEGATE:    func(n int) int { return -n },
+	word.ABS:       func(n int) int { return max(n, -n) },
+	word.INVERT:    func(n int) int { return ^n },
+	word.ONEPLUS:   func(n int) int { return n + 1 },
+	word.ONEMINUS:  func(n int) int { return n - 1 },
+	word.TWOSTAR:   func(n int) int { return n << 1 },
+	word.TWOSLASH:  func(n int) int { return n >> 1 },
+	word.ZEROEQ:    func(n int) int { return flag(n == 0) },
+	word.ZEROLT:    func(n int) int { return flag(n < 0) },
+	word.ZEROGT:    func(n int) int { return flag(n > 0) },
+	word.ZERONOTEQ: func(n int) int { return flag(n != 0) },
+}
+
+// wrap truncates n to the cell width, sign-extending the result.
+func (vm *VM) wrap(n int) int {
+	shift := strconv.IntSize - vm.bits
+	return n << shift >> shift
+}
+
+// unsigned returns n as an unsigned number of the cell width.
+func (vm *VM) unsigned(n int) uint {
+	shift := strconv.IntSize - vm.bits
+	return uint(n) << shift >> shift
+}
+
+// toDouble combines the two cells of a double-cell number, hi being the
+// most significant, into a single value.
+func (vm *VM) toDouble(lo, hi int) *big.Int {
+	d := big.NewInt(int64(hi))
+	d.Lsh(d, uint(vm.bits))
+	return d.Add(d, new(big.Int).SetUint64(uint64(vm.unsigned(lo))))
+}
+
+// toUDouble is like toDouble but treats the number as unsigned.
+func (vm *VM) toUDouble(lo, hi int) *big.Int {
+	d := new(big.Int).SetUint64(uint64(vm.unsigned(hi)))
+	d.Lsh(d, uint(vm.bits))
+	return d.Add(d, new(big.Int).SetUint64(uint64(vm.unsigned(lo))))
+}
+
+// fromDouble splits d into the two cells of a double-cell number, wrapping
+// it to twice the cell width.
+func (vm *VM) fromDouble(d *big.Int) (lo, hi int) {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(vm.bits))
+	mask.Sub(mask, big.NewInt(1))
+	l := new(big.Int).And(d, mask)
+	h := new(big.Int).Rsh(d, uint(vm.bits))
+	h.And(h, mask)
+	return vm.wrap(int(l.Uint64())), vm.wrap(int(h.Uint64()))
+}
+
+// toCell converts n to a single cell, failing if it does not fit.
+func (vm *VM) toCell(n *big.Int) (int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(vm.bits-1))
+	if n.Cmp(limit) >= 0 || n.Cmp(limit.Neg(limit)) < 0 {
+		return 0, errOutOfRange
+	}
+	return int(n.Int64()), nil
+}
+
+// divide divides n by d, returning the remainder and quotient. The quotient
+// is rounded towards negative infinity if floored is set and towards zero
+// otherwise. Both results must fit in a cell.
+func (vm *VM) divide(n *big.Int, d int, floored bool) (rem, quot int, err error) {
+	if d == 0 {
+		return 0, 0, errDivisionByZero
+	}
+	dd := big.NewInt(int64(d))
+	q, r := new(big.Int).QuoRem(n, dd, new(big.Int))
+	if floored && r.Sign() != 0 && r.Sign() != dd.Sign() {
+		q.Sub(q, big.NewInt(1))
+		r.Add(r, dd)
+	}
+	if quot, err = vm.toCell(q); err != nil {
+		return 0, 0, err
+	}
+	rem, err = vm.toCell(r)
+	return rem, quot, err
+}
+
+// mulDiv computes n1*n2/d with a double-width intermediate product,
+// returning the remainder and the quotient rounded towards zero.
+func (vm *VM) mulDiv(n1, n2, d int) (rem, quot int, err error) {
+	p := new(big.Int).Mul(big.NewInt(int64(n1)), big.NewInt(int64(n2)))
+	return vm.divide(p, d, false)
+}
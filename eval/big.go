@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/Jorghy-Del/gorth/stack"
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+// cells is the parameter stack as seen by words that deal only in
+// machine-sized cells, such as EMIT or TYPE. It lets those words run
+// unchanged when the VM uses arbitrary-precision cells.
+type cells interface {
+	Push(v int)
+	Pop() int
+	Top() int
+	Second() int
+	Len() int
+}
+
+// bigCells adapts a stack of arbitrary-precision integers to cells. Values
+// that do not fit in an int are truncated to their low bits when popped.
+type bigCells struct {
+	s *stack.Stack[*big.Int]
+}
+
+func (b bigCells) Push(v int)  { b.s.Push(big.NewInt(int64(v))) }
+func (b bigCells) Pop() int    { return int(b.s.Pop().Int64()) }
+func (b bigCells) Top() int    { return int(b.s.Top().Int64()) }
+func (b bigCells) Second() int { return int(b.s.Second().Int64()) }
+func (b bigCells) Len() int    { return b.s.Len() }
+
+// BigCells makes the VM use arbitrary-precision integers for its cells, so
+// arithmetic never overflows. The cell width set by CellWidth then only
+// applies to double-cell numbers, and to RSHIFT, which shifts a negative
+// number as an unsigned cell, as fixed-width cells do.
+func BigCells() Option {
+	return func(vm *VM) {
+		vm.big = true
+	}
+}
+
+// BigStack returns a copy of the parameter stack of a VM created with
+// BigCells, bottom first.
+func (vm *VM) BigStack() []*big.Int {
+	stk := vm.bs.Items()
+	for i, n := range stk {
+		stk[i] = new(big.Int).Set(n)
+	}
+	return stk
+}
+
+// cells returns the parameter stack in use.
+func (vm *VM) cells() cells {
+	if vm.big {
+		return bigCells{&vm.bs}
+	}
+	return &vm.s
+}
+
+// maxBigShift is the largest shift count LSHIFT and RSHIFT accept with
+// arbitrary-precision cells, which keeps a shift from allocating without
+// bound.
+const maxBigShift = 1 << 16
+
+// bigBinary is binary for arbitrary-precision cells. The functions must
+// not modify their operands, which may be shared with other stack entries.
+var bigBinary = map[word.WordType]func(n1, n2 *big.Int) *big.Int{
+	word.ADD:      func(n1, n2 *big.Int) *big.Int { return new(big.Int).Add(n1, n2) },
+	word.SUBTRACT: func(n1, n2 *big.Int) *big.Int { return new(big.Int).Sub(n1, n2) },
+	word.MULTIPLY: func(n1, n2 *big.Int) *big.Int { return new(big.Int).Mul(n1, n2) },
+	word.AND:      func(n1, n2 *big.Int) *big.Int { return new(big.Int).And(n1, n2) },
+	word.OR:       func(n1, n2 *big.Int) *big.Int { return new(big.Int).Or(n1, n2) },
+	word.XOR:      func(n1, n2 *big.Int) *big.Int { return new(big.Int).Xor(n1, n2) },
+	word.MIN:      func(n1, n2 *big.Int) *big.Int { return choose(n1.Cmp(n2) <= 0, n1, n2) },
+	word.MAX:      func(n1, n2 *big.Int) *big.Int { return choose(n1.Cmp(n2) >= 0, n1, n2) },
+	word.EQ:       func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) == 0) },
+	word.NOTEQ:    func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) != 0) },
+	word.LT:       func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) < 0) },
+	word.GT:       func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) > 0) },
+	word.LE:       func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) <= 0) },
+	word.GE:       func(n1, n2 *big.Int) *big.Int { return bigFlag(n1.Cmp(n2) >= 0) },
+}
+
+// bigUnary is unary for arbitrary-precision cells.
+var bigUnary = map[word.WordType]func(n *big.Int) *big.Int{
+	word.NEGATE:    func(n *big.Int) *big.Int { return new(big.Int).Neg(n) },
+	word.ABS:       func(n *big.Int) *big.Int { return new(big.Int).Abs(n) },
+	word.INVERT:    func(n *big.Int) *big.Int { return new(big.Int).Not(n) },
+	word.ONEPLUS:   func(n *big.Int) *big.Int { return new(big.Int).Add(n, big.NewInt(1)) },
+	word.ONEMINUS:  func(n *big.Int) *big.Int { return new(big.Int).Sub(n, big.NewInt(1)) },
+	word.TWOSTAR:   func(n *big.Int) *big.Int { return new(big.Int).Lsh(n, 1) },
+	word.TWOSLASH:  func(n *big.Int) *big.Int { return new(big.Int).Rsh(n, 1) },
+	word.ZEROEQ:    func(n *big.Int) *big.Int { return bigFlag(n.Sign() == 0) },
+	word.ZEROLT:    func(n *big.Int) *big.Int { return bigFlag(n.Sign() < 0) },
+	word.ZEROGT:    func(n *big.Int) *big.Int { return bigFlag(n.Sign() > 0) },
+	word.ZERONOTEQ: func(n *big.Int) *big.Int { return bigFlag(n.Sign() != 0) },
+}
+
+func bigFlag(b bool) *big.Int {
+	return big.NewInt(int64(flag(b)))
+}
+
+// choose returns n1 if b is set and n2 otherwise.
+func choose(b bool, n1, n2 *big.Int) *big.Int {
+	if b {
+		return n1
+	}
+	return n2
+}
+
+// executeBig runs t if it is one of the words that handle arbitrary-
+// precision cells themselves: stack manipulation, literals, arithmetic and
+// number printing. It reports whether t was handled; all other words
+// see the stack through bigCells.
+func (vm *VM) executeBig(t word.Word) (bool, error) {
+	s := &vm.bs
+	index := func() int { return int(s.Pop().Int64()) }
+	if ok, err := shuffle(s, t.Type, index); ok {
+		if err != nil {
+			return true, fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		return true, nil
+	}
+	if f, ok := bigBinary[t.Type]; ok {
+		n2 := s.Pop()
+		n1 := s.Pop()
+		s.Push(f(n1, n2))
+		return true, nil
+	}
+	if f, ok := bigUnary[t.Type]; ok {
+		s.Push(f(s.Pop()))
+		return true, nil
+	}
+
+	switch t.Type {
+	case word.INT:
+		n, ok := new(big.Int).SetString(t.Literal, 10)
+		if !ok {
+			return true, fmt.Errorf("invalid number %s", t.Literal)
+		}
+		s.Push(n)
+	case word.DIVIDE, word.MOD, word.SLASHMOD:
+		n2 := s.Pop()
+		n1 := s.Pop()
+		if n2.Sign() == 0 {
+			return true, fmt.Errorf("%s: %w", t.Literal, errDivisionByZero)
+		}
+		q, r := new(big.Int).QuoRem(n1, n2, new(big.Int))
+		switch t.Type {
+		case word.DIVIDE:
+			s.Push(q)
+		case word.MOD:
+			s.Push(r)
+		default:
+			s.Push(r)
+			s.Push(q)
+		}
+	case word.LSHIFT, word.RSHIFT:
+		u := s.Pop()
+		n := s.Pop()
+		if u.Sign() < 0 || u.Cmp(big.NewInt(maxBigShift)) > 0 {
+			return true, fmt.Errorf("%s: invalid shift count %s", t.Literal, u)
+		}
+		if t.Type == word.LSHIFT {
+			s.Push(new(big.Int).Lsh(n, uint(u.Int64())))
+			break
+		}
+		if n.Sign() < 0 {
+			// As with fixed-width cells, a negative number is shifted as
+			// an unsigned number of the cell width.
+			n = new(big.Int).Mod(n, new(big.Int).Lsh(big.NewInt(1), uint(vm.bits)))
+		}
+		s.Push(new(big.Int).Rsh(n, uint(u.Int64())))
+	case word.STARSLASH, word.STARSLASHMOD:
+		n3 := s.Pop()
+		n2 := s.Pop()
+		n1 := s.Pop()
+		if n3.Sign() == 0 {
+			return true, fmt.Errorf("%s: %w", t.Literal, errDivisionByZero)
+		}
+		q, r := new(big.Int).QuoRem(new(big.Int).Mul(n1, n2), n3, new(big.Int))
+		if t.Type == word.STARSLASHMOD {
+			s.Push(r)
+		}
+		s.Push(q)
+	case word.POP:
+		fmt.Fprintf(vm.out, "%s ", s.Pop())
+	default:
+		return false, nil
+	}
+	return true, nil
+}
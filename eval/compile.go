@@ -0,0 +1,474 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+var (
+	errUndefined   = errors.New("undefined word")
+	errCompileOnly = errors.New("compile-only word")
+	errUnbalanced  = errors.New("unbalanced control structure")
+	errNested      = errors.New("nested definition")
+
+	errInterpretOnly = errors.New("interpret-only word")
+
+	// ErrReturnStackOverflow is returned when calls between definitions nest
+	// deeper than the return stack allows.
+	ErrReturnStackOverflow = errors.New("return stack overflow")
+)
+
+// definition is a compiled colon definition. Immediate definitions are
+// run, rather than compiled, when they appear in a definition.
+type definition struct {
+	name      string
+	code      []instr
+	immediate bool
+
+	// xt is the execution token of the definition.
+	xt int
+
+	// deferred is set for words made by DEFER, which run the definition
+	// whose execution token is action.
+	deferred bool
+	action   int
+
+	// here is the size of data space when the definition was started.
+	// Forgetting the definition shrinks data space back to it.
+	here int
+
+	// wid is the wordlist the definition belongs to.
+	wid int
+
+	// marked is the search order saved by a word made by MARKER.
+	marked *searchOrder
+
+	// native is set for words defined in Go with Define.
+	native *native
+}
+
+type opcode int
+
+const (
+	opPrim    opcode = iota // run the primitive w
+	opLit                   // push n, or b when cells are arbitrary-precision
+	opFLit                  // push f on the floating-point stack
+	opCall                  // call def
+	opTail                  // jump to def, which returns to the caller's caller
+	opBranch                // jump n instructions, relative to the next one
+	opBranch0               // pop a flag and branch if it is zero
+	opCompile               // compile def, or w, into the current definition
+	opDefer                 // call the action of the deferred word def
+	opExecute               // pop an execution token and call its definition
+	opExit                  // return from the current definition
+	opMarker                // forget def and everything defined after it
+	opNative                // call the Go function of def
+)
+
+// instr is one instruction of threaded code.
+type instr struct {
+	op  opcode
+	w   word.Word
+	n   int
+	b   *big.Int
+	f   float64
+	def *definition
+}
+
+// frame is a return address: the code of a suspended definition and the
+// index of the instruction to resume at.
+type frame struct {
+	code []instr
+	ip   int
+}
+
+// orig is an unresolved control-flow reference left on the compiler's
+// control-flow stack: the word that left it and the index of its
+// instruction, or of the loop start for BEGIN.
+type orig struct {
+	kind word.WordType
+	at   int
+}
+
+// begin starts compiling a definition of name.
+func (vm *VM) begin(name string) {
+	vm.current = &definition{name: name, here: len(vm.data)}
+	vm.cf = nil
+	vm.setCompiling(true)
+}
+
+// abandon discards the definition being compiled, if any, and returns to
+// interpreting.
+func (vm *VM) abandon() {
+	vm.current = nil
+	vm.cf = nil
+	vm.setCompiling(false)
+}
+
+// emit appends ins to the definition being compiled.
+func (vm *VM) emit(ins instr) {
+	vm.current.code = append(vm.current.code, ins)
+}
+
+// compileWord compiles t into the current definition, running it instead
+// if it is immediate. next reads the word following POSTPONE.
+func (vm *VM) compileWord(t word.Word, next func() word.Word) error {
+	if def, ok := vm.lookup(t); ok {
+		if def.immediate {
+			return vm.run(def)
+		}
+		vm.emit(instr{op: opCall, w: t, def: def})
+		return nil
+	}
+	switch t.Type {
+	case word.POSTPONE:
+		return vm.postpone(next())
+	case word.BRACKETTICK:
+		def, err := vm.find(t, next())
+		if err != nil {
+			return err
+		}
+		vm.emit(vm.lit(t, def.xt))
+		return nil
+	case word.IS, word.ACTIONOF:
+		def, err := vm.findDeferred(t, next())
+		if err != nil {
+			return err
+		}
+		vm.emit(vm.lit(t, def.xt))
+		vm.emit(instr{op: opPrim, w: deferOp(t)})
+		return nil
+	}
+	return vm.compileToken(t)
+}
+
+// postpone compiles the compilation semantics of t: an immediate word is
+// compiled to be called, any other word to be compiled, when the current
+// definition runs.
+func (vm *VM) postpone(t word.Word) error {
+	if def, ok := vm.lookup(t); ok {
+		if def.immediate {
+			vm.emit(instr{op: opCall, w: t, def: def})
+		} else {
+			vm.emit(instr{op: opCompile, w: t, def: def})
+		}
+		return nil
+	}
+	switch t.Type {
+	case word.EOF:
+		return fmt.Errorf("postpone: missing name")
+	case word.ILLEGAL, word.UDF, word.INT, word.DOUBLE, word.FLOAT:
+		return fmt.Errorf("%s: %w", t.Literal, errUndefined)
+	}
+	vm.emit(instr{op: opCompile, w: t})
+	return nil
+}
+
+// compileToken compiles the built-in word or literal t into the current
+// definition. Control-flow words resolve their branches through the
+// control-flow stack, and ; ends the definition.
+func (vm *VM) compileToken(t word.Word) error {
+	var err error
+	switch t.Type {
+	case word.INT, word.DOUBLE, word.FLOAT, word.STRING, word.CSTRING:
+		code, err := vm.literal(t)
+		if err != nil {
+			return err
+		}
+		vm.current.code = append(vm.current.code, code...)
+	case word.ILLEGAL, word.UDF:
+		err = errUndefined
+	case word.DEFINE:
+		err = errNested
+	case word.RECURSE:
+		vm.emit(instr{op: opCall, w: t, def: vm.current})
+	case word.EXIT:
+		vm.tailCall()
+		vm.emit(instr{op: opExit, w: t})
+	case word.EXECUTE:
+		vm.emit(instr{op: opExecute, w: t})
+	case word.TICK, word.CREATE, word.VARIABLE, word.DEFER, word.SEE, word.FORGET, word.MARKER,
+		word.INCLUDE, word.REQUIRE:
+		err = errInterpretOnly
+	case word.SEMICOLON:
+		if len(vm.cf) > 0 {
+			return errUnbalanced
+		}
+		vm.tailCall()
+		vm.define(vm.current)
+		vm.abandon()
+	case word.LBRACKET:
+		vm.setCompiling(false)
+	case word.LITERAL:
+		if vm.big {
+			var b *big.Int
+			if b, err = vm.bs.PopE(); err == nil {
+				vm.emit(instr{op: opLit, w: t, b: b})
+			}
+		} else {
+			var n int
+			if n, err = vm.s.PopE(); err == nil {
+				vm.emit(instr{op: opLit, w: t, n: n})
+			}
+		}
+	case word.IF:
+		vm.cf = append(vm.cf, orig{word.IF, len(vm.current.code)})
+		vm.emit(instr{op: opBranch0, w: t})
+	case word.ELSE:
+		var o orig
+		if o, err = vm.popOrig(word.IF); err == nil {
+			vm.cf = append(vm.cf, orig{word.ELSE, len(vm.current.code)})
+			vm.emit(instr{op: opBranch, w: t})
+			vm.resolve(o.at)
+		}
+	case word.THEN:
+		var o orig
+		if o, err = vm.popOrig(word.IF, word.ELSE); err == nil {
+			vm.resolve(o.at)
+		}
+	case word.BEGIN:
+		vm.cf = append(vm.cf, orig{word.BEGIN, len(vm.current.code)})
+	case word.UNTIL, word.AGAIN:
+		var o orig
+		if o, err = vm.popOrig(word.BEGIN); err == nil {
+			op := opBranch0
+			if t.Type == word.AGAIN {
+				op = opBranch
+			}
+			vm.emit(instr{op: op, w: t, n: o.at - len(vm.current.code) - 1})
+		}
+	case word.WHILE:
+		if len(vm.cf) == 0 || vm.cf[len(vm.cf)-1].kind != word.BEGIN {
+			err = errUnbalanced
+			break
+		}
+		vm.cf = append(vm.cf, orig{word.WHILE, len(vm.current.code)})
+		vm.emit(instr{op: opBranch0, w: t})
+	case word.REPEAT:
+		var w, b orig
+		if w, err = vm.popOrig(word.WHILE); err == nil {
+			if b, err = vm.popOrig(word.BEGIN); err == nil {
+				vm.emit(instr{op: opBranch, w: t, n: b.at - len(vm.current.code) - 1})
+				vm.resolve(w.at)
+			}
+		}
+	default:
+		vm.emit(instr{op: opPrim, w: t})
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.Literal, err)
+	}
+	return nil
+}
+
+// tailCall turns a call that ends the current definition into a jump, so
+// that recursion in tail position runs in constant return stack space.
+func (vm *VM) tailCall() {
+	code := vm.current.code
+	if n := len(code); n > 0 && code[n-1].op == opCall {
+		code[n-1].op = opTail
+	}
+}
+
+// resolve points the branch at src to the next instruction compiled.
+func (vm *VM) resolve(src int) {
+	vm.current.code[src].n = len(vm.current.code) - src - 1
+}
+
+// popOrig pops the top of the control-flow stack if it was left by one of
+// kinds.
+func (vm *VM) popOrig(kinds ...word.WordType) (orig, error) {
+	if len(vm.cf) > 0 {
+		o := vm.cf[len(vm.cf)-1]
+		for _, k := range kinds {
+			if o.kind == k {
+				vm.cf = vm.cf[:len(vm.cf)-1]
+				return o, nil
+			}
+		}
+	}
+	return orig{}, errUnbalanced
+}
+
+// literal compiles the number or string literal t, doing the work of
+// parsing it once so that running the code does not have to. The
+// characters of a string are copied into data space when it is compiled.
+func (vm *VM) literal(t word.Word) ([]instr, error) {
+	switch t.Type {
+	case word.DOUBLE:
+		d, err := parseDouble(t)
+		if err != nil {
+			return nil, err
+		}
+		lo, hi := vm.fromDouble(d)
+		return []instr{vm.lit(t, lo), vm.lit(t, hi)}, nil
+	case word.FLOAT:
+		f, err := parseFloat(t)
+		if err != nil {
+			return nil, err
+		}
+		return []instr{{op: opFLit, w: t, f: f}}, nil
+	case word.STRING:
+		addr := len(vm.data)
+		vm.data = append(vm.data, t.Literal...)
+		return []instr{vm.lit(t, addr), vm.lit(t, len(t.Literal))}, nil
+	case word.CSTRING:
+		if len(t.Literal) > 255 {
+			return nil, errors.New(`c": string too long`)
+		}
+		addr := len(vm.data)
+		vm.data = append(vm.data, byte(len(t.Literal)))
+		vm.data = append(vm.data, t.Literal...)
+		return []instr{vm.lit(t, addr)}, nil
+	}
+
+	if vm.big {
+		b, ok := new(big.Int).SetString(t.Literal, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %s", t.Literal)
+		}
+		return []instr{{op: opLit, w: t, b: b}}, nil
+	}
+	n, err := strconv.Atoi(t.Literal)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %s", t.Literal)
+	}
+	return []instr{{op: opLit, w: t, n: vm.wrap(n)}}, nil
+}
+
+// lit returns an instruction that pushes n, compiled for w.
+func (vm *VM) lit(w word.Word, n int) instr {
+	if vm.big {
+		return instr{op: opLit, w: w, b: big.NewInt(int64(n))}
+	}
+	return instr{op: opLit, w: w, n: n}
+}
+
+// run executes def. Calls between definitions are made by pushing a frame
+// on the return stack rather than by recursing in Go.
+func (vm *VM) run(def *definition) error {
+	base := vm.rs.Len()
+	code, ip := def.code, 0
+	for {
+		if ip == len(code) {
+			if vm.rs.Len() == base {
+				return nil
+			}
+			f := vm.rs.Pop()
+			code, ip = f.code, f.ip
+			continue
+		}
+
+		ins := code[ip]
+		ip++
+		var err error
+		switch ins.op {
+		case opPrim:
+			err = vm.step(ins.w)
+		case opLit:
+			if vm.big {
+				err = vm.bs.PushE(ins.b)
+			} else {
+				err = vm.s.PushE(ins.n)
+			}
+		case opFLit:
+			err = vm.fs.PushE(ins.f)
+		case opCall:
+			err = vm.call(ins.w, frame{code, ip})
+			code, ip = ins.def.code, 0
+		case opTail:
+			code, ip = ins.def.code, 0
+		case opDefer:
+			// A deferred word only runs its action, so the action can
+			// return straight to the deferred word's caller.
+			a := ins.def.action
+			if a == 0 {
+				err = fmt.Errorf("%s: deferred word not set", ins.def.name)
+				break
+			}
+			code, ip = vm.xts[a-1].code, 0
+		case opExecute:
+			var xt int
+			if xt, err = vm.popXT(); err != nil {
+				err = fmt.Errorf("%s: %w", ins.w.Literal, err)
+				break
+			}
+			err = vm.call(ins.w, frame{code, ip})
+			code, ip = vm.xts[xt-1].code, 0
+		case opExit:
+			ip = len(code)
+		case opMarker:
+			vm.forget(ins.def)
+		case opNative:
+			err = vm.callNative(ins.def)
+		case opBranch:
+			ip += ins.n
+		case opBranch0:
+			var zero bool
+			if zero, err = vm.popZero(); err == nil && zero {
+				ip += ins.n
+			}
+		case opCompile:
+			switch {
+			case vm.current == nil:
+				err = fmt.Errorf("%s: %w", ins.w.Literal, errCompileOnly)
+			case ins.def != nil:
+				vm.emit(instr{op: opCall, w: ins.w, def: ins.def})
+			default:
+				err = vm.compileToken(ins.w)
+			}
+		}
+		if err != nil && (ins.op == opLit || ins.op == opFLit || ins.op == opBranch0) {
+			err = fmt.Errorf("%s: %w", ins.w.Literal, err)
+		}
+		if err != nil {
+			for vm.rs.Len() > base {
+				vm.rs.Pop()
+			}
+			return err
+		}
+	}
+}
+
+// call saves the return address f before a call made by w.
+func (vm *VM) call(w word.Word, f frame) error {
+	if err := vm.rs.PushE(f); err != nil {
+		return fmt.Errorf("%s: %w", w.Literal, ErrReturnStackOverflow)
+	}
+	return nil
+}
+
+// popXT pops an execution token.
+func (vm *VM) popXT() (int, error) {
+	var xt int
+	if vm.big {
+		n, err := vm.bs.PopE()
+		if err != nil {
+			return 0, err
+		}
+		xt = int(n.Int64())
+	} else {
+		var err error
+		if xt, err = vm.s.PopE(); err != nil {
+			return 0, err
+		}
+	}
+	if xt < 1 || xt > len(vm.xts) {
+		return 0, fmt.Errorf("invalid execution token %d", xt)
+	}
+	return xt, nil
+}
+
+// popZero pops a flag and reports whether it is false.
+func (vm *VM) popZero() (bool, error) {
+	if vm.big {
+		n, err := vm.bs.PopE()
+		return err == nil && n.Sign() == 0, err
+	}
+	n, err := vm.s.PopE()
+	return n == 0, err
+}
@@ -0,0 +1,196 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+// lookup finds the colon definition named by t. Numbers and string
+// literals never name a definition.
+func (vm *VM) lookup(t word.Word) (*definition, bool) {
+	switch t.Type {
+	case word.INT, word.DOUBLE, word.FLOAT, word.STRING, word.DOTQUOTE, word.CSTRING, word.EOF:
+		return nil, false
+	}
+	return vm.search(t.Literal)
+}
+
+// find returns the definition named by t, which was read by the parsing
+// word p, wrapping a built-in word in a definition of its own if needed.
+func (vm *VM) find(p, t word.Word) (*definition, error) {
+	if t.Type == word.EOF {
+		return nil, fmt.Errorf("%s: missing name", p.Literal)
+	}
+	if def, ok := vm.lookup(t); ok {
+		return def, nil
+	}
+	switch t.Type {
+	case word.INT, word.DOUBLE, word.FLOAT, word.STRING, word.DOTQUOTE, word.CSTRING, word.ILLEGAL, word.UDF:
+		return nil, fmt.Errorf("%s: %w", t.Literal, errUndefined)
+	}
+	return vm.primitive(t), nil
+}
+
+// immediateFlag is the flag FIND returns for def: 1 if it is immediate and
+// -1 otherwise.
+func immediateFlag(def *definition) int {
+	if def.immediate {
+		return 1
+	}
+	return -1
+}
+
+// immediates are the built-in words that act while compiling rather than
+// being compiled.
+var immediates = map[word.WordType]bool{
+	word.IF: true, word.ELSE: true, word.THEN: true,
+	word.BEGIN: true, word.UNTIL: true, word.AGAIN: true, word.WHILE: true, word.REPEAT: true,
+	word.SEMICOLON: true, word.LBRACKET: true, word.LITERAL: true, word.POSTPONE: true,
+	word.BRACKETTICK: true, word.RECURSE: true, word.IS: true, word.ACTIONOF: true,
+}
+
+// findName returns the definition of the word called name, if there is
+// one: the first found in the search order, or else the built-in word.
+func (vm *VM) findName(name string) (*definition, bool) {
+	if def, ok := vm.search(name); ok {
+		return def, true
+	}
+	return vm.builtin(name)
+}
+
+// builtin returns the definition of the built-in word called name.
+func (vm *VM) builtin(name string) (*definition, bool) {
+	if wT, ok := word.Table[strings.ToLower(name)]; ok {
+		return vm.primitive(word.Word{Type: wT, Literal: name}), true
+	}
+	return nil, false
+}
+
+// primitive returns the definition that runs the built-in word t, so that
+// built-in words have execution tokens like colon definitions.
+func (vm *VM) primitive(t word.Word) *definition {
+	if def, ok := vm.prims[t.Type]; ok {
+		return def
+	}
+	t.Literal = strings.ToLower(t.Literal)
+	ins := instr{op: opPrim, w: t}
+	if t.Type == word.EXECUTE {
+		ins.op = opExecute
+	}
+	def := &definition{name: t.Literal, code: []instr{ins}, immediate: immediates[t.Type]}
+	vm.register(def)
+	vm.prims[t.Type] = def
+	return def
+}
+
+// isPrimitive reports whether def is the definition made for a built-in
+// word by primitive.
+func (vm *VM) isPrimitive(def *definition) bool {
+	return len(def.code) == 1 && vm.prims[def.code[0].w.Type] == def
+}
+
+// define adds def to the compilation wordlist as the latest definition. A
+// word it replaces keeps its execution token, and definitions compiled
+// with it keep calling it; only later uses of the name see def.
+func (vm *VM) define(def *definition) {
+	def.wid = vm.order.compilation
+	list := vm.wordlists[def.wid-1]
+	_, ok := list[key(def.name)]
+	if ok || word.GetWordType(def.name, nil) != word.ILLEGAL {
+		fmt.Fprintf(vm.out, "redefined %s ", def.name)
+	}
+	vm.register(def)
+	list[key(def.name)] = def
+	vm.latest = def
+}
+
+// forget removes def and every word defined after it from the dictionary,
+// uncovering the words they redefined, and gives back the data space
+// allotted since def was started. Forgetting a marker also restores the
+// search order it saved.
+func (vm *VM) forget(def *definition) {
+	n := def.xt - 1
+	for t, p := range vm.prims {
+		if p.xt > n {
+			delete(vm.prims, t)
+		}
+	}
+	vm.xts = vm.xts[:n]
+	for i := range vm.wordlists {
+		vm.wordlists[i] = map[string]*definition{}
+	}
+	vm.latest = nil
+	for _, d := range vm.xts {
+		if vm.isPrimitive(d) {
+			continue
+		}
+		if d.action > n {
+			d.action = 0
+		}
+		vm.wordlists[d.wid-1][key(d.name)] = d
+		vm.latest = d
+	}
+	if def.here < len(vm.data) {
+		vm.data = vm.data[:def.here]
+	}
+	if def.marked != nil {
+		vm.order = *def.marked
+	}
+}
+
+// register gives def an execution token. Execution tokens are indexes,
+// counted from one, into vm.xts.
+func (vm *VM) register(def *definition) {
+	vm.xts = append(vm.xts, def)
+	def.xt = len(vm.xts)
+}
+
+// execute runs the definition with the execution token xt.
+func (vm *VM) execute(xt int) error {
+	if xt < 1 || xt > len(vm.xts) {
+		return fmt.Errorf("invalid execution token %d", xt)
+	}
+	return vm.run(vm.xts[xt-1])
+}
+
+// findDeferred is like find but requires a word made by DEFER.
+func (vm *VM) findDeferred(p, t word.Word) (*definition, error) {
+	def, err := vm.find(p, t)
+	if err == nil && !def.deferred {
+		err = fmt.Errorf("%s: not a deferred word", def.name)
+	}
+	return def, err
+}
+
+// deferredXT returns the deferred word with the execution token xt.
+func (vm *VM) deferredXT(xt int) (*definition, error) {
+	if xt < 1 || xt > len(vm.xts) {
+		return nil, fmt.Errorf("invalid execution token %d", xt)
+	}
+	def := vm.xts[xt-1]
+	if !def.deferred {
+		return nil, fmt.Errorf("%s: not a deferred word", def.name)
+	}
+	return def, nil
+}
+
+// setAction makes the deferred word def run the definition with the
+// execution token xt.
+func (vm *VM) setAction(t word.Word, def *definition, xt int) error {
+	if xt < 1 || xt > len(vm.xts) {
+		return fmt.Errorf("%s: invalid execution token %d", t.Literal, xt)
+	}
+	def.action = xt
+	return nil
+}
+
+// deferOp returns the word that IS or ACTION-OF, given as t, runs once the
+// execution token of its deferred word is on the stack.
+func deferOp(t word.Word) word.Word {
+	if t.Type == word.ACTIONOF {
+		return word.Word{Type: word.DEFERFETCH, Literal: t.Literal}
+	}
+	return word.Word{Type: word.DEFERSTORE, Literal: t.Literal}
+}
@@ -1,9 +1,15 @@
 package eval
 
 import (
+	"bufio"
 	"fmt"
-	"log"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
 
 	"errors"
 
@@ -11,94 +17,827 @@ import (
 	"github.com/Jorghy-Del/gorth/word"
 )
 
+// tibSize is the size of the terminal input buffer that REFILL reads into.
+// It occupies the start of data space.
+const tibSize = 256
+
+// stateAddr is the address of the cell that holds STATE, which follows the
+// terminal input buffer.
+const stateAddr = tibSize
+
+// maxDataSize is the largest data space ALLOT will grow to.
+const maxDataSize = 1 << 30
+
+var errDataSpaceFull = errors.New("data space full")
+
+// defaultReturnDepth is the return stack limit of a new VM.
+const defaultReturnDepth = 1 << 16
+
+// VM is an interpreter. Its parameter stack and data space persist between
+// calls to Execute.
+type VM struct {
+	s    stack.Stack[int]
+	fs   stack.Stack[float64]
+	data []byte
+
+	// wordlists holds the colon definitions of each wordlist by name; the
+	// wordlist with identifier wid is wordlists[wid-1]. order is the search
+	// order and compilation wordlist.
+	wordlists []map[string]*definition
+	order     searchOrder
+
+	// rs holds the return addresses of the definitions being run.
+	rs stack.Stack[frame]
+
+	// compiling is the STATE flag, which setCompiling keeps in step with
+	// the cell at stateAddr. current is the definition being compiled
+	// and cf its control-flow stack; latest is the last word defined.
+	compiling bool
+	current   *definition
+	cf        []orig
+	latest    *definition
+
+	// xts holds every definition that has an execution token, and prims the
+	// definitions made for built-in words.
+	xts   []*definition
+	prims map[word.WordType]*definition
+
+	// big is set when cells are arbitrary-precision integers, which are
+	// kept on bs instead of s.
+	big bool
+	bs  stack.Stack[*big.Int]
+
+	// bits is the cell width. Every value on the stack is kept sign-extended
+	// from this width, so arithmetic wraps as two's complement.
+	bits int
+
+	// tibLen is the length of the line last read by REFILL.
+	tibLen int
+
+	// includes is the stack of source files being included, innermost
+	// last, and included the set of every file included so far.
+	includes []string
+	included map[string]bool
+
+	// sources is the number of sources, such as strings passed to EVALUATE,
+	// being interpreted one inside another.
+	sources int
+
+	// out buffers everything the VM prints. It is flushed by CR, at the end
+	// of every line of input and when Execute returns.
+	out *bufio.Writer
+	in  *bufio.Reader
+
+	// src is the reader in buffers, kept so that KEY? can tell whether
+	// input is pending without waiting for it. Input that is not held in
+	// memory is read through an asyncReader.
+	src io.Reader
+}
+
+// An Option configures a VM created by New.
+type Option func(*VM)
+
+// CellWidth sets the cell width to 16, 32 or 64 bits. Widths wider than the
+// host's int are not supported. The default is the width of int.
+func CellWidth(bits int) Option {
+	if bits != 16 && bits != 32 && bits != 64 || bits > strconv.IntSize {
+		panic(fmt.Sprintf("eval: unsupported cell width %d", bits))
+	}
+	return func(vm *VM) {
+		vm.bits = bits
+	}
+}
+
+// MaxDepth limits the parameter and floating-point stacks to n items.
+// Pushing more reports a stack overflow.
+func MaxDepth(n int) Option {
+	return func(vm *VM) {
+		vm.s.Max = n
+		vm.fs.Max = n
+		vm.bs.Max = n
+	}
+}
+
+// ReturnDepth limits the return stack to n nested calls between
+// definitions. Calling deeper reports ErrReturnStackOverflow. Zero means no
+// limit; the default is 65536.
+func ReturnDepth(n int) Option {
+	return func(vm *VM) {
+		vm.rs.Max = n
+	}
+}
+
+// New returns a VM that prints to w and reads its input from r. A nil r is
+// treated as an empty input stream.
+func New(w io.Writer, r io.Reader, opts ...Option) *VM {
+	if r == nil {
+		r = strings.NewReader("")
+	}
+	if _, ok := r.(interface{ Len() int }); !ok {
+		r = newAsyncReader(r)
+	}
+	vm := &VM{
+		data:     make([]byte, tibSize),
+		bits:     strconv.IntSize,
+		prims:    map[word.WordType]*definition{},
+		included: map[string]bool{},
+		rs:       stack.Stack[frame]{Max: defaultReturnDepth},
+		out:      bufio.NewWriter(w),
+		in:       bufio.NewReader(r),
+		src:      r,
+	}
+	vm.newWordlist()
+	vm.order.only()
+	for _, opt := range opts {
+		opt(vm)
+	}
+	vm.data = append(vm.data, make([]byte, vm.cellSize())...)
+	return vm
+}
+
+// Flush writes any output still held in the buffer.
+func (vm *VM) Flush() error {
+	return vm.out.Flush()
+}
+
+// Stack returns a copy of the parameter stack, bottom first. With BigCells,
+// values that do not fit in an int are truncated; use BigStack instead.
+func (vm *VM) Stack() []int {
+	if vm.big {
+		stk := make([]int, vm.bs.Len())
+		for i, n := range vm.bs.Items() {
+			stk[i] = int(n.Int64())
+		}
+		return stk
+	}
+	return vm.s.Items()
+}
+
+// Push pushes n on the parameter stack.
+func (vm *VM) Push(n int) error {
+	if vm.big {
+		return vm.bs.PushE(big.NewInt(int64(n)))
+	}
+	return vm.s.PushE(vm.wrap(n))
+}
+
+// Pop removes the top of the parameter stack and returns it. With
+// BigCells, a value that does not fit in an int is truncated.
+func (vm *VM) Pop() (int, error) {
+	if vm.big {
+		n, err := vm.bs.PopE()
+		if err != nil {
+			return 0, err
+		}
+		return int(n.Int64()), nil
+	}
+	return vm.s.PopE()
+}
+
+// FloatStack returns a copy of the floating-point stack, bottom first.
+func (vm *VM) FloatStack() []float64 {
+	return vm.fs.Items()
+}
+
+// Execute runs tokens on a fresh VM attached to os.Stdout and os.Stdin.
 func Execute(tokens []word.Word) ([]int, error) {
-	var s stack.Stack
-	for _, t := range tokens {
-		switch t.Type {
-		case word.TRUE:
-			s.Push(-1)
-		case word.FALSE:
-			s.Push(0)
-		case word.AND:
-			s.Push(s.Pop() & s.Pop())
-		case word.OR:
-			s.Push(s.Pop() | s.Pop())
-		case word.INVERT:
-			s.Push(^s.Pop())
-		case word.EQ:
-			if s.Pop() == s.Pop() {
-				s.Push(int(word.TRUE))
-			} else {
-				s.Push(int(word.FALSE))
-			}
-		case word.LT:
-			v1 := s.Pop()
-			v2 := s.Pop()
-			if v2 < v1 {
-				s.Push(int(word.TRUE))
-			} else {
-				s.Push(int(word.FALSE))
+	return New(os.Stdout, os.Stdin).Execute(tokens)
+}
+
+// Execute interprets tokens and returns the resulting parameter stack.
+func (vm *VM) Execute(tokens []word.Word) ([]int, error) {
+	next := func() word.Word {
+		for len(tokens) > 0 {
+			t := tokens[0]
+			tokens = tokens[1:]
+			if t.Type != word.EOF {
+				return t
 			}
-		case word.GT:
-			v1 := s.Pop()
-			v2 := s.Pop()
-			if v2 > v1 {
-				s.Push(int(word.TRUE))
-			} else {
-				s.Push(int(word.FALSE))
+		}
+		return word.Word{Type: word.EOF, Literal: "0x00"}
+	}
+	err := vm.interpret(next)
+	return vm.Stack(), err
+}
+
+// step runs the single word t.
+func (vm *VM) step(t word.Word) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok || !errors.Is(e, stack.ErrUnderflow) && !errors.Is(e, stack.ErrOverflow) {
+				panic(r)
 			}
-		case word.ADD:
-			s.Push(s.Pop() + s.Pop())
-		case word.SUBTRACT:
-			s.Push(s.Pop() - s.Pop())
-		case word.MULTIPLY:
-			s.Push(s.Pop() * s.Pop())
-		case word.DIVIDE:
-			s.Push(s.Pop() / s.Pop())
-		case word.MOD:
-			f := s.Pop()
-			sec := s.Pop()
-			s.Push(sec % f)
-		case word.POP:
-			top := s.Pop()
-			fmt.Println(top)
-		case word.DUP:
-			top := s.Top()
-			s.Push(top)
-		case word.DROP:
-			s.Pop()
-		case word.SWAP:
-			first := s.Pop()
-			second := s.Pop()
-			s.Push(first)
-			s.Push(second)
-		case word.OVER:
-			sec := s.Second()
-			s.Push(sec)
-		case word.SPIN:
-			n1, n2, n3 := s.Pop(), s.Pop(), s.Pop()
-			s.Push(n2)
-			s.Push(n3)
-			s.Push(n1)
-		case word.EMIT:
-			n := s.Pop()
-			fmt.Println(string(rune(n)))
-		case word.CR:
-			fmt.Println()
-		case word.EOF:
-			fmt.Println()
-		case word.ILLEGAL:
-			fmt.Printf("%x of type %d is illegal.\n", t.Literal, t.Type)
-		case word.INT:
-			v, e := strconv.Atoi(t.Literal)
-			if e != nil {
-				log.Fatal(e)
+			err = fmt.Errorf("%s: %w", t.Literal, e)
+		}
+	}()
+
+	s, out := vm.cells(), vm.out
+	if vm.big {
+		ok, err := vm.executeBig(t)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	} else if ok, err := shuffle(&vm.s, t.Type, s.Pop); ok {
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		return nil
+	}
+	if f, ok := binary[t.Type]; ok {
+		n2 := s.Pop()
+		n1 := s.Pop()
+		s.Push(vm.wrap(f(n1, n2)))
+		return nil
+	}
+	if f, ok := unary[t.Type]; ok {
+		s.Push(vm.wrap(f(s.Pop())))
+		return nil
+	}
+
+	switch t.Type {
+	case word.TRUE:
+		s.Push(flag(true))
+	case word.FALSE:
+		s.Push(flag(false))
+	case word.WITHIN:
+		n3 := s.Pop()
+		n2 := s.Pop()
+		n1 := s.Pop()
+		s.Push(flag(vm.unsigned(n1-n2) < vm.unsigned(n3-n2)))
+	case word.RSHIFT:
+		u := s.Pop()
+		n := s.Pop()
+		s.Push(vm.wrap(int(vm.unsigned(n) >> uint(u))))
+	case word.DIVIDE, word.MOD, word.SLASHMOD:
+		n2 := s.Pop()
+		n1 := s.Pop()
+		rem, quot, err := vm.divide(big.NewInt(int64(n1)), n2, false)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		if t.Type != word.DIVIDE {
+			s.Push(rem)
+		}
+		if t.Type != word.MOD {
+			s.Push(quot)
+		}
+	case word.STARSLASH, word.STARSLASHMOD:
+		n3 := s.Pop()
+		n2 := s.Pop()
+		n1 := s.Pop()
+		rem, quot, err := vm.mulDiv(n1, n2, n3)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		if t.Type == word.STARSLASHMOD {
+			s.Push(rem)
+		}
+		s.Push(quot)
+	case word.FMMOD, word.SMREM:
+		n := s.Pop()
+		hi := s.Pop()
+		lo := s.Pop()
+		rem, quot, err := vm.divide(vm.toDouble(lo, hi), n, t.Type == word.FMMOD)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		s.Push(rem)
+		s.Push(quot)
+	case word.DOUBLE:
+		d, err := parseDouble(t)
+		if err != nil {
+			return err
+		}
+		vm.pushDouble(d)
+	case word.DPLUS, word.DMINUS:
+		d2 := vm.popDouble()
+		d1 := vm.popDouble()
+		if t.Type == word.DPLUS {
+			vm.pushDouble(d1.Add(d1, d2))
+		} else {
+			vm.pushDouble(d1.Sub(d1, d2))
+		}
+	case word.DNEGATE:
+		d := vm.popDouble()
+		vm.pushDouble(d.Neg(d))
+	case word.DABS:
+		d := vm.popDouble()
+		vm.pushDouble(d.Abs(d))
+	case word.DDOT:
+		fmt.Fprintf(out, "%s ", vm.popDouble())
+	case word.DDOTR:
+		width := s.Pop()
+		fmt.Fprintf(out, "%*s", width, vm.popDouble())
+	case word.DLT, word.DEQ:
+		d2 := vm.popDouble()
+		d1 := vm.popDouble()
+		if t.Type == word.DLT {
+			s.Push(flag(d1.Cmp(d2) < 0))
+		} else {
+			s.Push(flag(d1.Cmp(d2) == 0))
+		}
+	case word.MSTAR:
+		n2 := s.Pop()
+		n1 := s.Pop()
+		vm.pushDouble(new(big.Int).Mul(big.NewInt(int64(n1)), big.NewInt(int64(n2))))
+	case word.UMSTAR:
+		u2 := new(big.Int).SetUint64(uint64(vm.unsigned(s.Pop())))
+		u1 := new(big.Int).SetUint64(uint64(vm.unsigned(s.Pop())))
+		vm.pushDouble(u1.Mul(u1, u2))
+	case word.UMSLASHMOD:
+		u := vm.unsigned(s.Pop())
+		hi := s.Pop()
+		lo := s.Pop()
+		if u == 0 {
+			return fmt.Errorf("%s: %w", t.Literal, errDivisionByZero)
+		}
+		q, r := new(big.Int).QuoRem(vm.toUDouble(lo, hi), new(big.Int).SetUint64(uint64(u)), new(big.Int))
+		if q.BitLen() > vm.bits {
+			return fmt.Errorf("%s: %w", t.Literal, errOutOfRange)
+		}
+		s.Push(vm.wrap(int(r.Uint64())))
+		s.Push(vm.wrap(int(q.Uint64())))
+	case word.STOD:
+		vm.pushDouble(big.NewInt(int64(s.Pop())))
+	case word.DTOS:
+		s.Pop()
+	case word.FLOAT:
+		f, err := parseFloat(t)
+		if err != nil {
+			return err
+		}
+		vm.fs.Push(f)
+	case word.FPLUS, word.FMINUS, word.FSTAR, word.FSLASH:
+		r2 := vm.fs.Pop()
+		r1 := vm.fs.Pop()
+		switch t.Type {
+		case word.FPLUS:
+			vm.fs.Push(r1 + r2)
+		case word.FMINUS:
+			vm.fs.Push(r1 - r2)
+		case word.FSTAR:
+			vm.fs.Push(r1 * r2)
+		case word.FSLASH:
+			vm.fs.Push(r1 / r2)
+		}
+	case word.FDOT:
+		out.WriteString(formatFloat(vm.fs.Pop()))
+		out.WriteByte(' ')
+	case word.FDUP:
+		vm.fs.Push(vm.fs.Top())
+	case word.FDROP:
+		vm.fs.Pop()
+	case word.FSWAP:
+		r2 := vm.fs.Pop()
+		r1 := vm.fs.Pop()
+		vm.fs.Push(r2)
+		vm.fs.Push(r1)
+	case word.FLT:
+		r2 := vm.fs.Pop()
+		r1 := vm.fs.Pop()
+		s.Push(flag(r1 < r2))
+	case word.FSQRT:
+		vm.fs.Push(math.Sqrt(vm.fs.Pop()))
+	case word.FSIN:
+		vm.fs.Push(math.Sin(vm.fs.Pop()))
+	case word.FCOS:
+		vm.fs.Push(math.Cos(vm.fs.Pop()))
+	case word.FLOOR:
+		vm.fs.Push(math.Floor(vm.fs.Pop()))
+	case word.FROUND:
+		vm.fs.Push(math.RoundToEven(vm.fs.Pop()))
+	case word.STOF:
+		vm.fs.Push(float64(s.Pop()))
+	case word.FTOS:
+		r := vm.fs.Pop()
+		if math.IsNaN(r) || math.IsInf(r, 0) {
+			return fmt.Errorf("%s: %w", t.Literal, errOutOfRange)
+		}
+		i, _ := big.NewFloat(r).Int(nil)
+		n, err := vm.toCell(i)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		s.Push(n)
+	case word.POP:
+		top := s.Pop()
+		fmt.Fprintf(out, "%d ", top)
+	case word.EMIT:
+		n := s.Pop()
+		out.WriteRune(rune(n))
+	case word.CR:
+		out.WriteByte('\n')
+		out.Flush()
+	case word.SPACE:
+		out.WriteByte(' ')
+	case word.SPACES:
+		for n := s.Pop(); n > 0; n-- {
+			out.WriteByte(' ')
+		}
+	case word.TYPE:
+		u := s.Pop()
+		addr := s.Pop()
+		b, err := vm.region(addr, u)
+		if err != nil {
+			return fmt.Errorf("type: %w", err)
+		}
+		out.Write(b)
+	case word.STRING:
+		s.Push(len(vm.data))
+		s.Push(len(t.Literal))
+		vm.data = append(vm.data, t.Literal...)
+	case word.DOTQUOTE:
+		out.WriteString(t.Literal)
+	case word.CSTRING:
+		if len(t.Literal) > 255 {
+			return errors.New(`c": string too long`)
+		}
+		s.Push(len(vm.data))
+		vm.data = append(vm.data, byte(len(t.Literal)))
+		vm.data = append(vm.data, t.Literal...)
+	case word.COUNT:
+		addr := s.Pop()
+		b, err := vm.region(addr, 1)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		s.Push(addr + 1)
+		s.Push(int(b[0]))
+	case word.KEY:
+		out.Flush()
+		r, _, err := vm.in.ReadRune()
+		if err != nil {
+			return errors.New("key: end of input")
+		}
+		s.Push(int(r))
+	case word.KEYQ:
+		out.Flush()
+		s.Push(flag(vm.keyReady()))
+	case word.ACCEPT:
+		n := s.Pop()
+		addr := s.Pop()
+		b, err := vm.region(addr, n)
+		if err != nil {
+			return fmt.Errorf("accept: %w", err)
+		}
+		out.Flush()
+		s.Push(vm.accept(b))
+	case word.REFILL:
+		out.Flush()
+		if _, err := vm.in.Peek(1); err != nil {
+			s.Push(flag(false))
+			break
+		}
+		vm.tibLen = vm.accept(vm.data[:tibSize])
+		s.Push(flag(true))
+	case word.SOURCE:
+		s.Push(0)
+		s.Push(vm.tibLen)
+	case word.HERE:
+		s.Push(len(vm.data))
+	case word.ALLOT:
+		n := s.Pop()
+		if n < 0 {
+			return fmt.Errorf("allot: negative size %d", n)
+		}
+		if n > maxDataSize-len(vm.data) {
+			return fmt.Errorf("allot: %w", errDataSpaceFull)
+		}
+		vm.data = append(vm.data, make([]byte, n)...)
+	case word.FETCH:
+		b, err := vm.region(s.Pop(), vm.cellSize())
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		s.Push(vm.fetch(b))
+	case word.STORE:
+		b, err := vm.region(s.Pop(), vm.cellSize())
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		vm.store(b, s.Pop())
+	case word.COMMA:
+		b := make([]byte, vm.cellSize())
+		vm.store(b, s.Pop())
+		vm.data = append(vm.data, b...)
+	case word.CELLS:
+		s.Push(vm.wrap(s.Pop() * vm.cellSize()))
+	case word.EXECUTE:
+		if err := vm.execute(s.Pop()); err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+	case word.COMPILECOMMA:
+		xt := s.Pop()
+		if vm.current == nil {
+			return fmt.Errorf("%s: %w", t.Literal, errCompileOnly)
+		}
+		if xt < 1 || xt > len(vm.xts) {
+			return fmt.Errorf("%s: invalid execution token %d", t.Literal, xt)
+		}
+		def := vm.xts[xt-1]
+		vm.emit(instr{op: opCall, w: word.Word{Type: word.UDF, Literal: def.name}, def: def})
+	case word.DEFERFETCH:
+		def, err := vm.deferredXT(s.Pop())
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		s.Push(def.action)
+	case word.DEFERSTORE:
+		def, err := vm.deferredXT(s.Pop())
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		return vm.setAction(t, def, s.Pop())
+	case word.WORDS:
+		vm.words()
+	case word.FIND:
+		addr := s.Pop()
+		b, err := vm.region(addr, 1)
+		if err == nil {
+			b, err = vm.region(addr+1, int(b[0]))
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		if def, ok := vm.findName(string(b)); ok {
+			s.Push(def.xt)
+			s.Push(immediateFlag(def))
+		} else {
+			s.Push(addr)
+			s.Push(0)
+		}
+	case word.SEARCHWORDLIST:
+		wid := s.Pop()
+		u := s.Pop()
+		b, err := vm.region(s.Pop(), u)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		if err := vm.checkWordlist(wid); err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		if def, ok := vm.searchWordlist(wid, string(b)); ok {
+			s.Push(def.xt)
+			s.Push(immediateFlag(def))
+		} else {
+			s.Push(0)
+		}
+	case word.FORTHWORDLIST:
+		s.Push(forthWordlist)
+	case word.WORDLIST:
+		s.Push(vm.newWordlist())
+	case word.GETORDER:
+		for i := len(vm.order.wids) - 1; i >= 0; i-- {
+			s.Push(vm.order.wids[i])
+		}
+		s.Push(len(vm.order.wids))
+	case word.SETORDER:
+		n := s.Pop()
+		switch {
+		case n == -1:
+			vm.order.wids = []int{forthWordlist}
+			return nil
+		case n < 0:
+			return fmt.Errorf("%s: invalid count %d", t.Literal, n)
+		case n > s.Len():
+			return fmt.Errorf("%s: %w", t.Literal, stack.ErrUnderflow)
+		}
+		wids := make([]int, n)
+		for i := range wids {
+			wids[i] = s.Pop()
+			if err := vm.checkWordlist(wids[i]); err != nil {
+				return fmt.Errorf("%s: %w", t.Literal, err)
 			}
-			s.Push(v)
-		default:
-			log.Fatalf("reached default %s (%T) has type %v\n", t.Literal, t.Literal, t.Type)
-			return s.Stk, errors.New("reached default.")
 		}
+		vm.order.wids = wids
+	case word.GETCURRENT:
+		s.Push(vm.order.compilation)
+	case word.SETCURRENT:
+		wid := s.Pop()
+		if err := vm.checkWordlist(wid); err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		vm.order.compilation = wid
+	case word.ONLY:
+		vm.order.only()
+	case word.ALSO, word.PREVIOUS, word.DEFINITIONS, word.FORTH:
+		if len(vm.order.wids) == 0 {
+			return fmt.Errorf("%s: %w", t.Literal, errEmptyOrder)
+		}
+		switch t.Type {
+		case word.ALSO:
+			vm.order.wids = slices.Insert(vm.order.wids, 0, vm.order.wids[0])
+		case word.PREVIOUS:
+			vm.order.wids = vm.order.wids[1:]
+		case word.DEFINITIONS:
+			vm.order.compilation = vm.order.wids[0]
+		case word.FORTH:
+			vm.order.wids[0] = forthWordlist
+		}
+	case word.EVALUATE:
+		u := s.Pop()
+		b, err := vm.region(s.Pop(), u)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		return vm.interpretSource(t.Literal, string(b))
+	case word.INCLUDED, word.REQUIRED:
+		u := s.Pop()
+		b, err := vm.region(s.Pop(), u)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.Literal, err)
+		}
+		return vm.include(string(b), t.Type == word.REQUIRED)
+	case word.EOF:
+		out.Flush()
+	case word.INT:
+		v, e := strconv.Atoi(t.Literal)
+		if e != nil {
+			return fmt.Errorf("invalid number %s", t.Literal)
+		}
+		s.Push(vm.wrap(v))
+	case word.IMMEDIATE:
+		if vm.latest == nil {
+			return fmt.Errorf("%s: no definition", t.Literal)
+		}
+		vm.latest.immediate = true
+	case word.STATE:
+		s.Push(stateAddr)
+	case word.UDF, word.ILLEGAL:
+		def, ok := vm.search(t.Literal)
+		if !ok {
+			return fmt.Errorf("%s: %w", t.Literal, errUndefined)
+		}
+		return vm.run(def)
+	case word.IF, word.ELSE, word.THEN, word.BEGIN, word.UNTIL, word.AGAIN, word.WHILE, word.REPEAT,
+		word.SEMICOLON, word.LBRACKET, word.RBRACKET, word.LITERAL, word.POSTPONE, word.BRACKETTICK,
+		word.RECURSE, word.EXIT:
+		return fmt.Errorf("%s: %w", t.Literal, errCompileOnly)
+	default:
+		return fmt.Errorf("%s: cannot execute word of type %d", t.Literal, t.Type)
+	}
+	return nil
+}
+
+// shuffle runs t on s if it is a stack manipulation word, reporting
+// whether it was one. index pops the argument of PICK and ROLL.
+func shuffle[T any](s *stack.Stack[T], t word.WordType, index func() int) (bool, error) {
+	var err error
+	switch t {
+	case word.DUP:
+		err = s.Pick(0)
+	case word.DROP:
+		_, err = s.PopE()
+	case word.SWAP:
+		err = s.Roll(1)
+	case word.OVER:
+		err = s.Pick(1)
+	case word.ROT:
+		err = s.Rot()
+	case word.PICK:
+		err = s.Pick(index())
+	case word.ROLL:
+		err = s.Roll(index())
+	case word.SPIN:
+		if err = s.Rot(); err == nil {
+			err = s.Roll(1)
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// popDouble pops a double-cell number.
+func (vm *VM) popDouble() *big.Int {
+	s := vm.cells()
+	hi := s.Pop()
+	lo := s.Pop()
+	return vm.toDouble(lo, hi)
+}
+
+// pushDouble pushes d as a double-cell number.
+func (vm *VM) pushDouble(d *big.Int) {
+	lo, hi := vm.fromDouble(d)
+	s := vm.cells()
+	s.Push(lo)
+	s.Push(hi)
+}
+
+// setCompiling sets STATE, both the flag the outer interpreter tests and
+// the cell that STATE @ reads.
+func (vm *VM) setCompiling(b bool) {
+	vm.compiling = b
+	vm.store(vm.data[stateAddr:stateAddr+vm.cellSize()], flag(b))
+}
+
+// cellSize is the size of a cell in data space, in bytes.
+func (vm *VM) cellSize() int {
+	return vm.bits / 8
+}
+
+// fetch returns the cell stored little-endian in b.
+func (vm *VM) fetch(b []byte) int {
+	var u uint64
+	for i := len(b) - 1; i >= 0; i-- {
+		u = u<<8 | uint64(b[i])
+	}
+	return vm.wrap(int(u))
+}
+
+// store stores n little-endian in b.
+func (vm *VM) store(b []byte, n int) {
+	for i := range b {
+		b[i] = byte(n)
+		n >>= 8
+	}
+}
+
+// region returns the u bytes of data space starting at addr.
+func (vm *VM) region(addr, u int) ([]byte, error) {
+	if addr < 0 || u < 0 || addr > len(vm.data) || u > len(vm.data)-addr {
+		return nil, fmt.Errorf("invalid address %d", addr)
+	}
+	return vm.data[addr : addr+u], nil
+}
+
+// accept reads a line of input into b and returns the number of characters
+// stored. Reading stops at a newline, which is not stored, when b is full or
+// at the end of input.
+func (vm *VM) accept(b []byte) int {
+	n := 0
+	for n < len(b) {
+		c, err := vm.in.ReadByte()
+		if err != nil || c == '\n' {
+			break
+		}
+		b[n] = c
+		n++
+	}
+	if n > 0 && b[n-1] == '\r' {
+		n--
+	}
+	return n
+}
+
+// keyReady reports whether a character can be read without waiting: one
+// is buffered, left in input held in memory, as by a strings.Reader, or
+// already received from any other input.
+func (vm *VM) keyReady() bool {
+	if vm.in.Buffered() > 0 {
+		return true
+	}
+	switch r := vm.src.(type) {
+	case interface{ Len() int }:
+		return r.Len() > 0
+	case *asyncReader:
+		return r.ready()
+	}
+	return false
+}
+
+// parseDouble parses the double-cell number t, written with a trailing
+// point.
+func parseDouble(t word.Word) (*big.Int, error) {
+	d, ok := new(big.Int).SetString(strings.TrimSuffix(t.Literal, "."), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %s", t.Literal)
+	}
+	return d, nil
+}
+
+// parseFloat parses the floating-point number t, whose exponent may be
+// empty as in 1e.
+func parseFloat(t word.Word) (float64, error) {
+	lit := t.Literal
+	if strings.HasSuffix(lit, "e") || strings.HasSuffix(lit, "E") {
+		lit += "0"
+	}
+	f, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", t.Literal)
+	}
+	return f, nil
+}
+
+// formatFloat formats r the way F. prints it: in full, with a trailing
+// point when it has no fractional part.
+func formatFloat(r float64) string {
+	f := strconv.FormatFloat(r, 'f', -1, 64)
+	if math.IsNaN(r) || math.IsInf(r, 0) || strings.Contains(f, ".") {
+		return f
+	}
+	return f + "."
+}
+
+// flag converts b to a well-formed Forth flag.
+func flag(b bool) int {
+	if b {
+		return -1
 	}
-	return s.Stk, nil
+	return 0
 }
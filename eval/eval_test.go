@@ -1,10 +1,21 @@
 package eval
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Jorghy-Del/gorth/lexer"
+	"github.com/Jorghy-Del/gorth/stack"
 	"github.com/Jorghy-Del/gorth/word"
 )
 
@@ -101,13 +112,13 @@ func TestEvalTable(t *testing.T) {
 			},
 		},
 		{
-			name:       "subtract two from one",
+			name:       "subtract one from two",
 			input:      `2 1 -`,
 			dictionary: map[word.Word][]word.Word{},
 			output: []expected{
 				{word.INT, "2", map[word.Word][]word.Word{}, []int{2}},
 				{word.INT, "1", map[word.Word][]word.Word{}, []int{2, 1}},
-				{word.SUBTRACT, "-", map[word.Word][]word.Word{}, []int{-1}},
+				{word.SUBTRACT, "-", map[word.Word][]word.Word{}, []int{1}},
 			},
 		},
 		{
@@ -201,9 +212,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 					[]int{2},
@@ -211,9 +222,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "10",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 					[]int{2, 10},
@@ -221,9 +232,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "double",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 					[]int{2, 20},
@@ -231,9 +242,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "double",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 					[]int{2, 40},
@@ -242,16 +253,15 @@ func TestEvalTable(t *testing.T) {
 		},
 		{
 			name:       "udf: evaluate half",
-			input:      `: half 2 swap / ; 100 half`,
+			input:      `: half 2 / ; 100 half`,
 			dictionary: map[word.Word][]word.Word{},
 			output: []expected{
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{},
@@ -259,10 +269,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "100",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{100},
@@ -270,10 +279,9 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "half",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{50},
@@ -282,15 +290,15 @@ func TestEvalTable(t *testing.T) {
 		},
 		{
 			name:       "udf: evaluate double then half",
-			input:      `: double dup + ; : half 2 swap / ; 100 double half`,
+			input:      `: double dup + ; : half 2 / ; 100 double half`,
 			dictionary: map[word.Word][]word.Word{},
 			output: []expected{
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 					[]int{},
@@ -298,14 +306,13 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{},
@@ -313,14 +320,13 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "100",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{100},
@@ -328,14 +334,13 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "double",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{200},
@@ -343,14 +348,13 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "half",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 					[]int{100},
@@ -365,12 +369,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isTruthy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isTruthy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{},
@@ -378,12 +382,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "10",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isTruthy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isTruthy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{10},
@@ -391,12 +395,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "isTruthy?",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isTruthy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isTruthy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{10, -1},
@@ -411,12 +415,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isFalsy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isFalsy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{},
@@ -424,12 +428,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "0",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isFalsy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isFalsy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{0},
@@ -437,12 +441,12 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "isFalsy?",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "isFalsy?"}: []word.Word{
-							{word.IF, "if"},
-							{word.INT, "-1"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "isFalsy?"}: []word.Word{
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "-1"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{0, -1},
@@ -457,16 +461,16 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.DEFINE, ":",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "buzz?"}: []word.Word{
-							{word.INT, "5"},
-							{word.MOD, "mod"},
-							{word.INT, "0"},
-							{word.EQ, "="},
-							{word.IF, "if"},
-							{word.INT, "420"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "buzz?"}: []word.Word{
+							{Type: word.INT, Literal: "5"},
+							{Type: word.MOD, Literal: "mod"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.EQ, Literal: "="},
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "420"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{},
@@ -474,16 +478,16 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.INT, "10",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "buzz?"}: []word.Word{
-							{word.INT, "5"},
-							{word.MOD, "mod"},
-							{word.INT, "0"},
-							{word.EQ, "="},
-							{word.IF, "if"},
-							{word.INT, "420"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "buzz?"}: []word.Word{
+							{Type: word.INT, Literal: "5"},
+							{Type: word.MOD, Literal: "mod"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.EQ, Literal: "="},
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "420"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{10},
@@ -491,16 +495,16 @@ func TestEvalTable(t *testing.T) {
 				{
 					word.UDF, "buzz?",
 					map[word.Word][]word.Word{
-						word.Word{word.UDF, "buzz?"}: []word.Word{
-							{word.INT, "5"},
-							{word.MOD, "mod"},
-							{word.INT, "0"},
-							{word.EQ, "="},
-							{word.IF, "if"},
-							{word.INT, "420"},
-							{word.ELSE, "else"},
-							{word.INT, "0"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "buzz?"}: []word.Word{
+							{Type: word.INT, Literal: "5"},
+							{Type: word.MOD, Literal: "mod"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.EQ, Literal: "="},
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "420"},
+							{Type: word.ELSE, Literal: "else"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 					[]int{10, 420},
@@ -512,14 +516,13 @@ func TestEvalTable(t *testing.T) {
 		l := lexer.New(tc.input, tc.dictionary)
 		tokens := []word.Word{}
 
-
 		for n, o := range tc.output {
 			tok := l.NextToken()
 			switch tok.Type {
 			case word.DEFINE:
 				l.DefineWord()
 			case word.UDF:
-				def := l.Dictionary[word.Word{word.UDF, tok.Literal}]
+				def := l.Dictionary[word.Word{Type: word.UDF, Literal: tok.Literal}]
 				isConditional := false
 				// for _, t := range def {
 				for i := 0; i < len(def); i++ {
@@ -562,3 +565,1788 @@ func TestEvalTable(t *testing.T) {
 		}
 	}
 }
+
+func TestOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"emit composes on one line", `72 emit 105 emit`, "Hi"},
+		{"dot is followed by a space", `1 2 . .`, "2 1 "},
+		{"negative numbers", `-5 .`, "-5 "},
+		{"cr", `1 . cr 2 .`, "1 \n2 "},
+		{"space and spaces", `1 . space 2 . 3 spaces 3 .`, "1  2    3 "},
+		{"zero and negative spaces print nothing", `0 spaces -4 spaces 1 .`, "1 "},
+		{"dot quote", `." hello world" cr`, "hello world\n"},
+		{"type", `s" forth" type 33 emit`, "forth!"},
+		{"type twice", `s" ab" s" cd" type type`, "cdab"},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		vm := New(&buf, nil)
+		_, err := vm.Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tc.expected {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestOutputIsBuffered(t *testing.T) {
+	var buf bytes.Buffer
+	vm := New(&buf, nil)
+
+	// seen records what has reached buf each time it runs.
+	var seen []string
+	if err := vm.Define("seen", "( -- )", func(*VM) error {
+		seen = append(seen, buf.String())
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vm.Define("flush", "( -- )", func(vm *VM) error {
+		return vm.Flush()
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := vm.Interpret(`1 . seen cr seen 2 . seen flush seen 3 .`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"", "1 \n", "1 \n", "1 \n2 "}
+	if !slices.Equal(seen, expected) {
+		t.Fatalf("wrong output while running. expected=%q, got=%q", expected, seen)
+	}
+	if buf.String() != "1 \n2 3 " {
+		t.Fatalf("wrong output. expected=%q, got=%q", "1 \n2 3 ", buf.String())
+	}
+}
+
+func TestVMKeepsStateBetweenCalls(t *testing.T) {
+	var buf bytes.Buffer
+	vm := New(&buf, strings.NewReader(""))
+
+	if _, err := vm.Execute(lex(`1 2 s" hi"`)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := vm.Execute(lex(`type +`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(got, []int{3}) {
+		t.Fatalf("wrong evaluation. expected=%v, got=%v", []int{3}, got)
+	}
+	if buf.String() != "hi" {
+		t.Fatalf("wrong output. expected=%q, got=%q", "hi", buf.String())
+	}
+}
+
+func TestInput(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		stdin       string
+		expectedStk []int
+		expectedOut string
+	}{
+		{"key", `key key`, "ab", []int{97, 98}, ""},
+		{"key? with input", `key?`, "x", []int{-1}, ""},
+		{"key? at end of input", `key?`, "", []int{0}, ""},
+		{"key echo", `key emit key emit`, "ok", []int{}, "ok"},
+		{"accept a line", `here 80 allot dup 80 accept type`, "hello\nworld\n", []int{}, "hello"},
+		{"accept stops at the buffer size", `here 3 allot dup 3 accept type key emit`, "abcdef", []int{}, "abcd"},
+		{"accept strips carriage return", `here 10 allot 10 accept`, "hi\r\n", []int{2}, ""},
+		{"accept at end of input", `here 10 allot 10 accept`, "", []int{0}, ""},
+		{"refill and source", `refill source type refill source type`, "one\ntwo", []int{-1, -1}, "onetwo"},
+		{"refill at end of input", `refill refill`, "last\n", []int{-1, 0}, ""},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		vm := New(&buf, strings.NewReader(tc.stdin))
+		got, err := vm.Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+			if buf.String() != tc.expectedOut {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.expectedOut, buf.String())
+			}
+		})
+	}
+}
+
+func TestKeyAtEndOfInput(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil)
+	if _, err := vm.Execute(lex(`key`)); err == nil {
+		t.Fatalf("expected an error reading past the end of input")
+	}
+}
+
+func TestKeyQuestionDoesNotWait(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	vm := New(&bytes.Buffer{}, r)
+
+	done := make(chan error)
+	go func() {
+		_, err := vm.Execute(lex(`key?`))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("key? waited for input")
+	}
+	if got := vm.Stack(); !slices.Equal(got, []int{0}) {
+		t.Fatalf("wrong evaluation. expected=%v, got=%v", []int{0}, got)
+	}
+}
+
+func TestKeyQuestionSeesPipedInput(t *testing.T) {
+	for _, name := range []string{"io.Pipe", "os.Pipe"} {
+		var r io.Reader
+		var w io.WriteCloser
+		if name == "os.Pipe" {
+			pr, pw, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pr.Close()
+			r, w = pr, pw
+		} else {
+			r, w = io.Pipe()
+		}
+		vm := New(&bytes.Buffer{}, r)
+		go w.Write([]byte("x"))
+
+		done := make(chan error)
+		go func() {
+			_, err := vm.Execute(lex(`: wait begin key? until ; wait key? key`))
+			done <- err
+		}()
+		t.Run(name, func(t *testing.T) {
+			defer w.Close()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("key? never saw the input")
+			}
+			if got := vm.Stack(); !slices.Equal(got, []int{-1, 'x'}) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", []int{-1, 'x'}, got)
+			}
+		})
+	}
+}
+
+func TestDataSpaceErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"type past the end", `-1 1 rshift 1 type`, fmt.Sprint("type: invalid address ", math.MaxInt)},
+		{"fetch past the end", `-1 1 rshift @`, fmt.Sprint("@: invalid address ", math.MaxInt)},
+		{"negative length", `0 -1 type`, "type: invalid address 0"},
+		{"allot too much", `-1 1 rshift allot`, "allot: data space full"},
+		{"negative allot", `-1 allot`, "allot: negative size -1"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedStk []int
+	}{
+		{"/mod", `7 2 /mod`, []int{1, 3}},
+		{"/mod negative dividend", `-7 2 /mod`, []int{-1, -3}},
+		{"*/", `100 3 4 */`, []int{75}},
+		{"*/ does not overflow its intermediate", `-1 1 rshift 4 8 */`, []int{math.MaxInt / 2}},
+		{"*/mod", `10 10 7 */mod`, []int{2, 14}},
+		{"negate", `5 negate -3 negate`, []int{-5, 3}},
+		{"abs", `-5 abs 5 abs 0 abs`, []int{5, 5, 0}},
+		{"min", `3 -4 min 2 9 min`, []int{-4, 2}},
+		{"max", `3 -4 max 2 9 max`, []int{3, 9}},
+		{"1+ 1-", `1 1+ 1 1-`, []int{2, 0}},
+		{"2* 2/", `3 2* 7 2/ -7 2/`, []int{6, 3, -4}},
+		{"fm/mod positive", `7 0 2 fm/mod`, []int{1, 3}},
+		{"fm/mod floors towards negative infinity", `-7 -1 2 fm/mod`, []int{1, -4}},
+		{"fm/mod negative divisor", `7 0 -2 fm/mod`, []int{-1, -4}},
+		{"sm/rem truncates towards zero", `-7 -1 2 sm/rem`, []int{-1, -3}},
+		{"sm/rem negative divisor", `7 0 -2 sm/rem`, []int{1, -3}},
+		{"fm/mod double-cell dividend", `1 1 4 fm/mod`, []int{1, math.MaxInt/2 + 1}},
+		{"upper case names", `3 NEGATE 1+`, []int{-2}},
+	}
+	for _, tc := range tests {
+		got, err := New(&bytes.Buffer{}, nil).Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+		})
+	}
+}
+
+func TestOperandOrder(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedStk []int
+	}{
+		{`10 3 +`, []int{13}},
+		{`10 3 -`, []int{7}},
+		{`3 10 -`, []int{-7}},
+		{`10 3 *`, []int{30}},
+		{`10 2 /`, []int{5}},
+		{`2 10 /`, []int{0}},
+		{`-7 2 /`, []int{-3}},
+		{`10 3 mod`, []int{1}},
+		{`3 10 mod`, []int{3}},
+		{`10 3 %`, []int{1}},
+		{`10 3 /mod`, []int{1, 3}},
+		{`3 10 /mod`, []int{3, 0}},
+		{`10 3 2 */`, []int{15}},
+		{`2 3 10 */`, []int{0}},
+		{`10 3 4 */mod`, []int{2, 7}},
+		{`10 3 min`, []int{3}},
+		{`10 3 max`, []int{10}},
+		{`10 0 3 fm/mod`, []int{1, 3}},
+		{`10 0 3 sm/rem`, []int{1, 3}},
+		{`10 3 <`, []int{0}},
+		{`10 3 >`, []int{-1}},
+	}
+	for _, tc := range tests {
+		got, err := New(&bytes.Buffer{}, nil).Execute(lex(tc.input))
+
+		t.Run(tc.input, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+		})
+	}
+}
+
+func TestBitwiseAndComparison(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedStk []int
+	}{
+		{`12 10 xor`, []int{6}},
+		{`1 4 lshift`, []int{16}},
+		{`-1 64 lshift`, []int{0}},
+		{`256 4 rshift`, []int{16}},
+		{fmt.Sprintf("-1 %d rshift", strconv.IntSize-4), []int{15}},
+		{`0 0= 5 0=`, []int{-1, 0}},
+		{`-5 0< 0 0< 5 0<`, []int{-1, 0, 0}},
+		{`-5 0> 0 0> 5 0>`, []int{0, 0, -1}},
+		{`0 0<> 7 0<>`, []int{0, -1}},
+		{`3 4 <> 4 4 <>`, []int{-1, 0}},
+		{`3 4 <= 4 4 <= 5 4 <=`, []int{-1, -1, 0}},
+		{`3 4 >= 4 4 >= 5 4 >=`, []int{0, -1, -1}},
+		{`1 -1 u< -1 1 u<`, []int{-1, 0}},
+		{`1 -1 u> -1 1 u>`, []int{0, -1}},
+		{`5 1 10 within 10 1 10 within 1 1 10 within 0 1 10 within`, []int{-1, 0, -1, 0}},
+		{`5 10 1 within 0 10 1 within`, []int{0, -1}},
+		{`-5 -10 -1 within`, []int{-1}},
+		{`1 2 = 2 2 =`, []int{0, -1}},
+		{`true false`, []int{-1, 0}},
+	}
+	for _, tc := range tests {
+		got, err := New(&bytes.Buffer{}, nil).Execute(lex(tc.input))
+
+		t.Run(tc.input, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+		})
+	}
+}
+
+func TestCellWidth(t *testing.T) {
+	tests := []struct {
+		bits        int
+		input       string
+		expectedStk []int
+	}{
+		{16, `32767 1 +`, []int{-32768}},
+		{16, `-32768 1 -`, []int{32767}},
+		{16, `300 300 *`, []int{24464}},
+		{16, `40000`, []int{-25536}},
+		{16, `-32768 negate -32768 abs`, []int{-32768, -32768}},
+		{16, `16384 2*`, []int{-32768}},
+		{16, `1 16 lshift 1 15 lshift`, []int{0, -32768}},
+		{16, `-1 1 rshift -1 12 rshift`, []int{32767, 15}},
+		{16, `1 -1 u< -1 1 u>`, []int{-1, -1}},
+		{16, `-1 0 32767 within`, []int{0}},
+		{16, `1000 100 1000 */`, []int{100}},
+		{16, `20000 3 4 */`, []int{15000}},
+		{16, `1 1 4 fm/mod`, []int{1, 16384}},
+		{32, `2147483647 1+`, []int{-2147483648}},
+		{32, `65536 65536 *`, []int{0}},
+		{32, `-1 1 rshift`, []int{2147483647}},
+		{64, `9223372036854775807 1+`, []int{math.MinInt}},
+		{64, `-1 1 rshift`, []int{math.MaxInt}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d/%s", tc.bits, tc.input), func(t *testing.T) {
+			if tc.bits > strconv.IntSize {
+				t.Skip("cell width wider than int")
+			}
+			vm := New(&bytes.Buffer{}, nil, CellWidth(tc.bits))
+			got, err := vm.Execute(lex(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+		})
+	}
+}
+
+func TestCellWidthRange(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`30000 3 2 */`, "*/: result out of range"},
+		{`0 1 2 fm/mod`, "fm/mod: result out of range"},
+		{`-32768 -1 /`, "/: result out of range"},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil, CellWidth(16))
+		_, err := vm.Execute(lex(tc.input))
+
+		t.Run(tc.input, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestCellWidthIsPortable(t *testing.T) {
+	input := `7 3 - 12 * 5 /mod 1000 swap 3 */ -3 2* negate xor`
+	var results [][]int
+	for _, bits := range []int{16, 32, 64} {
+		if bits > strconv.IntSize {
+			continue
+		}
+		got, err := New(&bytes.Buffer{}, nil, CellWidth(bits)).Execute(lex(input))
+		if err != nil {
+			t.Fatalf("%d bits: unexpected error: %v", bits, err)
+		}
+		results = append(results, got)
+	}
+	for i := 1; i < len(results); i++ {
+		if !slices.Equal(results[0], results[i]) {
+			t.Fatalf("results differ between cell widths: %v", results)
+		}
+	}
+}
+
+func TestUnsupportedCellWidth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected CellWidth(8) to panic")
+		}
+	}()
+	CellWidth(8)
+}
+
+func TestDoubleCell(t *testing.T) {
+	tests := []struct {
+		name        string
+		bits        int
+		input       string
+		expectedStk []int
+		expectedOut string
+	}{
+		{"literal", 64, `123.`, []int{123, 0}, ""},
+		{"negative literal", 64, `-2.`, []int{-2, -1}, ""},
+		{"literal wider than a cell", 64, `18446744073709551616.`, []int{0, 1}, ""},
+		{"d.", 64, `-42. d.`, []int{}, "-42 "},
+		{"d+ carries into the high cell", 64, `18446744073709551615. 1. d+`, []int{0, 1}, ""},
+		{"d+ prints past a single cell", 64, `9223372036854775807. 9223372036854775807. d+ d.`, []int{}, "18446744073709551614 "},
+		{"d-", 64, `10. 3. d- 3. 10. d-`, []int{7, 0, -7, -1}, ""},
+		{"dnegate", 64, `5. dnegate d.`, []int{}, "-5 "},
+		{"dabs", 64, `-5. dabs`, []int{5, 0}, ""},
+		{"d.r", 64, `42. 6 d.r -1. 3 d.r`, []int{}, "    42 -1"},
+		{"d<", 64, `1. 2. d< 2. 1. d< -1. 1. d<`, []int{-1, 0, -1}, ""},
+		{"d=", 64, `7. 7. d= 7. 8. d=`, []int{-1, 0}, ""},
+		{"m* keeps the full product", 64, `9223372036854775807 4 m* d.`, []int{}, "36893488147419103228 "},
+		{"m* signs", 64, `-3 4 m*`, []int{-12, -1}, ""},
+		{"um* is unsigned", 64, `-1 2 um*`, []int{-2, 1}, ""},
+		{"um/mod", 64, `0 1 4 um/mod`, []int{0, math.MaxInt/2 + 1}, ""},
+		{"um/mod remainder", 64, `17 0 5 um/mod`, []int{2, 3}, ""},
+		{"s>d d>s", 64, `-7 s>d 7 s>d d>s`, []int{-7, -1, 7}, ""},
+		{"128-bit intermediate for money", 64, `1000000000000 1000000000 m* 1000000000000 um/mod`, []int{0, 1000000000}, ""},
+		{"16-bit doubles", 16, `32767 1 m* d. 65535. 1. d+`, []int{0, 1}, "32767 "},
+		{"16-bit d+ overflow wraps", 16, `2147483647. 1. d+ d.`, []int{}, "-2147483648 "},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.bits > strconv.IntSize {
+				t.Skip("cell width wider than int")
+			}
+			var buf bytes.Buffer
+			got, err := New(&buf, nil, CellWidth(tc.bits)).Execute(lex(tc.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+			if buf.String() != tc.expectedOut {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.expectedOut, buf.String())
+			}
+		})
+	}
+}
+
+func TestFloat(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expectedStk []int
+		expectedFs  []float64
+		expectedOut string
+	}{
+		{"literals", `1.5e0 2e -3E2 1.25e-1`, []int{}, []float64{1.5, 2, -300, 0.125}, ""},
+		{"literals stay off the parameter stack", `7 1e0`, []int{7}, []float64{1}, ""},
+		{"f+ f- f* f/", `1.5e0 2e f+ 10e 4e f- 3e 2e f* 1e 4e f/`, []int{}, []float64{3.5, 6, 6, 0.25}, ""},
+		{"f.", `1.5e0 f. 3e f. -2.25e0 f.`, []int{}, []float64{}, "1.5 3. -2.25 "},
+		{"fdup fdrop fswap", `1e 2e fswap fdup 3e fdrop`, []int{}, []float64{2, 1, 1}, ""},
+		{"f<", `1e 2e f< 2e 1e f< 1e 1e f<`, []int{-1, 0, 0}, []float64{}, ""},
+		{"fsqrt", `16e fsqrt`, []int{}, []float64{4}, ""},
+		{"fsin fcos", `0e fsin 0e fcos`, []int{}, []float64{0, 1}, ""},
+		{"floor", `2.5e0 floor -2.5e0 floor`, []int{}, []float64{2, -3}, ""},
+		{"fround", `2.5e0 fround 3.5e0 fround -2.7e0 fround`, []int{}, []float64{2, 4, -3}, ""},
+		{"s>f", `-3 s>f 0.5e0 f+`, []int{}, []float64{-2.5}, ""},
+		{"f>s truncates", `3.9e0 f>s -3.9e0 f>s`, []int{3, -3}, []float64{}, ""},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		vm := New(&buf, nil)
+		got, err := vm.Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expectedStk) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+			}
+			if fs := vm.FloatStack(); !slices.Equal(fs, tc.expectedFs) {
+				t.Fatalf("wrong float stack. expected=%v, got=%v", tc.expectedFs, fs)
+			}
+			if buf.String() != tc.expectedOut {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.expectedOut, buf.String())
+			}
+		})
+	}
+}
+
+func TestBigCellsMatchFixedWidth(t *testing.T) {
+	tests := []string{
+		`1 -1 +`,
+		`10 3 - 3 10 -`,
+		`-7 2 / -7 2 mod 7 -2 /mod`,
+		`10 3 4 */ 10 3 4 */mod`,
+		`12 10 and 12 10 or 12 10 xor 5 invert`,
+		`1 4 lshift 256 4 rshift`,
+		`-1 1 rshift -8 2 rshift`,
+		`3 -4 min 3 -4 max 5 negate -5 abs`,
+		`1 1+ 1 1- 3 2* -7 2/`,
+		`0 0= 5 0< -5 0< 5 0> 0 0<>`,
+		`1 2 = 1 2 <> 1 2 < 1 2 > 1 1 <= 1 1 >=`,
+		`1 2 3 dup drop swap over spin`,
+		`true false`,
+		`72 emit 1 . 2 3 .`,
+		`-7 s>d 123. d+`,
+	}
+	for _, input := range tests {
+		var fixedOut, bigOut bytes.Buffer
+		fixed, err := New(&fixedOut, nil).Execute(lex(input))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", input, err)
+		}
+		vm := New(&bigOut, nil, BigCells())
+		_, err = vm.Execute(lex(input))
+
+		t.Run(input, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var got []int
+			for _, n := range vm.BigStack() {
+				got = append(got, int(n.Int64()))
+			}
+			if !slices.Equal(got, fixed) {
+				t.Fatalf("results differ. fixed=%v, big=%v", fixed, got)
+			}
+			if bigOut.String() != fixedOut.String() {
+				t.Fatalf("output differs. fixed=%q, big=%q", fixedOut.String(), bigOut.String())
+			}
+		})
+	}
+}
+
+func TestBigCells(t *testing.T) {
+	factorial := "1"
+	for i := 2; i <= 30; i++ {
+		factorial += fmt.Sprintf(" %d *", i)
+	}
+	fibonacci := "0 1"
+	for i := 0; i < 99; i++ {
+		fibonacci += " swap over +"
+	}
+	tests := []struct {
+		name        string
+		input       string
+		expectedOut string
+	}{
+		{"30 factorial", factorial + " .", "265252859812191058636308480000000 "},
+		{"100th fibonacci number", fibonacci + " . drop", "354224848179261915075 "},
+		{"literals wider than 64 bits", `100000000000000000000 1 + .`, "100000000000000000001 "},
+		{"negative results", `1 100 lshift negate .`, "-1267650600228229401496703205376 "},
+		{"division", `100000000000000000000 3 /mod . .`, "33333333333333333333 1 "},
+		{"*/ keeps its intermediate exact", `100000000000000000000 100000000000000000000 7 */ .`, "1428571428571428571428571428571428571428 "},
+		{"comparison flags", `100000000000000000000 1 > .`, "-1 "},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		_, err := New(&buf, nil, BigCells()).Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tc.expectedOut {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.expectedOut, buf.String())
+			}
+		})
+	}
+}
+
+func TestBigCellsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"negative lshift count", `1 -1 lshift`, "lshift: invalid shift count -1"},
+		{"negative rshift count", `1 -1 rshift`, "rshift: invalid shift count -1"},
+		{"huge lshift count", `1 1000000000000 lshift`, "lshift: invalid shift count 1000000000000"},
+		{"division by zero", `1 0 /`, "/: division by zero"},
+	}
+	for _, tc := range tests {
+		_, err := New(&bytes.Buffer{}, nil, BigCells()).Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestStackWords(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedStk []int
+	}{
+		{`1 2 3 rot`, []int{2, 3, 1}},
+		{`1 2 3 spin`, []int{2, 1, 3}},
+		{`10 20 30 0 pick`, []int{10, 20, 30, 30}},
+		{`10 20 30 2 pick`, []int{10, 20, 30, 10}},
+		{`10 20 30 1 roll`, []int{10, 30, 20}},
+		{`10 20 30 2 roll`, []int{20, 30, 10}},
+		{`1 2 over over`, []int{1, 2, 1, 2}},
+	}
+	for _, tc := range tests {
+		for _, opts := range [][]Option{nil, {BigCells()}} {
+			got, err := New(&bytes.Buffer{}, nil, opts...).Execute(lex(tc.input))
+
+			t.Run(tc.input, func(t *testing.T) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !slices.Equal(got, tc.expectedStk) {
+					t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expectedStk, got)
+				}
+			})
+		}
+	}
+}
+
+func TestStackErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected error
+		message  string
+	}{
+		{"binary underflow", `1 +`, nil, stack.ErrUnderflow, "+: stack underflow"},
+		{"dup on empty stack", `dup`, nil, stack.ErrUnderflow, "dup: stack underflow"},
+		{"pick past the bottom", `1 2 5 pick`, nil, stack.ErrUnderflow, "pick: stack underflow"},
+		{"emit on empty stack", `emit`, nil, stack.ErrUnderflow, "emit: stack underflow"},
+		{"float underflow", `1e f+`, nil, stack.ErrUnderflow, "f+: stack underflow"},
+		{"big underflow", `1 *`, []Option{BigCells()}, stack.ErrUnderflow, "*: stack underflow"},
+		{"overflow", `1 2 3 4`, []Option{MaxDepth(3)}, stack.ErrOverflow, "4: stack overflow"},
+		{"dup overflows", `1 2 3 dup`, []Option{MaxDepth(3)}, stack.ErrOverflow, "dup: stack overflow"},
+	}
+	for _, tc := range tests {
+		_, err := New(&bytes.Buffer{}, nil, tc.opts...).Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("wrong error. expected=%v, got=%v", tc.expected, err)
+			}
+			if err.Error() != tc.message {
+				t.Fatalf("wrong message. expected=%q, got=%q", tc.message, err.Error())
+			}
+		})
+	}
+}
+
+func TestArithmeticErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"/ by zero", `1 0 /`, "/: division by zero"},
+		{"mod by zero", `1 0 mod`, "mod: division by zero"},
+		{"/mod by zero", `1 0 /mod`, "/mod: division by zero"},
+		{"/ out of range", `-1 1 rshift invert -1 /`, "/: result out of range"},
+		{"mod out of range", `-1 1 rshift invert -1 mod`, "mod: result out of range"},
+		{"/mod out of range", `-1 1 rshift invert -1 /mod`, "/mod: result out of range"},
+		{"*/ by zero", `1 2 0 */`, "*/: division by zero"},
+		{"fm/mod by zero", `1 0 0 fm/mod`, "fm/mod: division by zero"},
+		{"sm/rem quotient out of range", `0 4 2 sm/rem`, "sm/rem: result out of range"},
+		{"um/mod by zero", `1. 0 um/mod`, "um/mod: division by zero"},
+		{"f>s out of range", `1e30 f>s`, "f>s: result out of range"},
+		{"f>s of infinity", `1e 0e f/ f>s`, "f>s: result out of range"},
+		{"um/mod quotient out of range", `0 1 1 um/mod`, "um/mod: result out of range"},
+	}
+	for _, tc := range tests {
+		_, err := New(&bytes.Buffer{}, nil).Execute(lex(tc.input))
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestColonDefinitions(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"literals", `: three 1 2 + ; three three`, []int{3, 3}},
+		{"calls", `: sq dup * ; : cube dup sq * ; 3 cube`, []int{27}},
+		{"if then", `: abs' dup 0< if negate then ; -4 abs' 5 abs'`, []int{4, 5}},
+		{"if else then", `: sign 0< if -1 else 1 then ; -7 sign 7 sign`, []int{-1, 1}},
+		{"nested if", `: cmp over over < if drop drop -1 else > if 1 else 0 then then ; 1 2 cmp 2 1 cmp 2 2 cmp`, []int{-1, 1, 0}},
+		{"begin until", `: count 0 begin 1+ dup 5 = until ; count`, []int{5}},
+		{"begin while repeat", `: sum 0 swap begin dup while swap over + swap 1- repeat drop ; 4 sum`, []int{10}},
+		{"empty loop body", `: zero begin dup while 1- repeat ; 3 zero`, []int{0}},
+		{"early binding", `: one 1 ; : two one one + ; : one 10 ; two one`, []int{2, 10}},
+		{"double literal", `: d 5. -1. ; d`, []int{5, 0, -1, -1}},
+		{"float literal", `: f 2.5e ; f f f+ f>s`, []int{5}},
+		{"string literal is stored once", `: s s" hi" ; s drop s drop =`, []int{-1}},
+		{"string literal leaves here alone", `: name s" hi" ; create t 1 , name drop drop 2 , t 1 cells + @`, []int{2}},
+		{"counted string literal", `: c c" abc" ; c count swap c 1+ =`, []int{3, -1}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompiledCode(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil)
+	if err := vm.Interpret(`: f begin dup 0< while 1+ repeat 0= if 7 then ;`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []struct {
+		op opcode
+		n  int
+	}{
+		{opPrim, 0},    // dup
+		{opPrim, 0},    // 0<
+		{opBranch0, 2}, // while: to 0=
+		{opPrim, 0},    // 1+
+		{opBranch, -5}, // repeat: to dup
+		{opPrim, 0},    // 0=
+		{opBranch0, 1}, // if: past 7
+		{opLit, 7},
+	}
+	code := vm.wordlists[0]["f"].code
+	if len(code) != len(expected) {
+		t.Fatalf("wrong code length. expected=%d, got=%d", len(expected), len(code))
+	}
+	for i, e := range expected {
+		if code[i].op != e.op || code[i].n != e.n {
+			t.Fatalf("wrong instruction %d. expected=%v, got=%v", i, e, code[i])
+		}
+	}
+
+	if err := vm.Interpret(`: k 1. 2e ;`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, op := range []opcode{opLit, opLit, opFLit} {
+		if got := vm.wordlists[0]["k"].code[i].op; got != op {
+			t.Fatalf("number literal %d is not parsed when compiled: %v", i, got)
+		}
+	}
+
+	if err := vm.Interpret(`: g f ; : h f 1 ;`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if op := vm.wordlists[0]["g"].code[0].op; op != opTail {
+		t.Fatalf("call before ; is not a tail call: %v", op)
+	}
+	if op := vm.wordlists[0]["h"].code[0].op; op != opCall {
+		t.Fatalf("call before a literal is a tail call: %v", op)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"undefined word", `: f nope ;`, "f: nope: undefined word"},
+		{"then without if", `: f then ;`, "f: then: unbalanced control structure"},
+		{"unterminated if", `: f 1 if 2 ;`, "f: unbalanced control structure"},
+		{"repeat without while", `: f begin repeat ;`, "f: repeat: unbalanced control structure"},
+		{"while without begin", `: f 1 if while then ;`, "f: while: unbalanced control structure"},
+		{"if outside a definition", `1 if`, "if: compile-only word"},
+		{"error inside a definition", `: f 1 0 / ; f`, "/: division by zero"},
+		{"branch on empty stack", `: f if then ; f`, "if: stack underflow"},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+			if vm.rs.Len() != 0 {
+				t.Fatalf("return stack not unwound: %d frames", vm.rs.Len())
+			}
+			if vm.compiling || vm.current != nil {
+				t.Fatalf("definition not abandoned")
+			}
+		})
+	}
+}
+
+func TestRecurseAndExit(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"factorial", `: fact dup 1 > if dup 1- recurse * then ; 5 fact`, []int{120}},
+		{"fibonacci", `: fib dup 2 < if exit then dup 1- recurse swap 2 - recurse + ; 10 fib`, []int{55}},
+		{"exit", `: f 1 exit 2 ; f`, []int{1}},
+		{"exit from a loop", `: f 0 begin 1+ dup 3 = if exit then again ; f 4`, []int{3, 4}},
+		{"recurse calls the new version", `: f 1 ; : f dup 0> if 1- recurse then ; 3 f`, []int{0}},
+		{"compiled execute", `: apply execute ; 3 ' dup apply`, []int{3, 3}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestReturnStackOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int
+		input    string
+		expected string
+	}{
+		{"recurse", 100, `: f recurse 1 ; f`, "recurse: return stack overflow"},
+		{"default limit", 0, `: f recurse 1 ; f`, "recurse: return stack overflow"},
+		{"deferred word", 100, `defer g : f g 1 ; ' f is g f`, "g: return stack overflow"},
+		{"execute", 100, `: f dup execute ; ' f f`, "execute: return stack overflow"},
+		{"depth is enough", 2, `: a 1 ; : b a 1 ; : c b 1 ; c`, ""},
+		{"depth is one short", 1, `: a 1 ; : b a 1 ; : c b 1 ; c`, "a: return stack overflow"},
+	}
+	for _, tc := range tests {
+		var opts []Option
+		if tc.depth > 0 {
+			opts = append(opts, ReturnDepth(tc.depth))
+		}
+		vm := New(&bytes.Buffer{}, nil, opts...)
+		err := vm.Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.expected == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrReturnStackOverflow) || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+			if vm.rs.Len() != 0 {
+				t.Fatalf("return stack not unwound: %d frames", vm.rs.Len())
+			}
+		})
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"million-deep recursion", `: down dup 0> if 1- recurse then ; 1000000 down`, []int{0}},
+		{"tail call before exit", `: down dup 0= if exit then 1- recurse exit ; 1000000 down`, []int{0}},
+		{"gcd", `: gcd dup 0= if drop exit then swap over mod recurse ; 1071 462 gcd`, []int{21}},
+		{"mutual recursion", `defer odd?
+			: even? dup 0= if drop -1 exit then 1- odd? ;
+			: (odd?) dup 0= if drop 0 exit then 1- even? ;
+			' (odd?) is odd? 1000000 even? 1000001 even?`, []int{-1, 0}},
+	}
+	for _, tc := range tests {
+		// The return stack is too shallow for any of these to run
+		// without tail calls.
+		vm := New(&bytes.Buffer{}, nil, ReturnDepth(4))
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedefinition(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+		output   string
+	}{
+		{"new word", `: double dup + ; 1 double`, []int{2}, ""},
+		{"earlier definitions keep the old version",
+			`: double dup + ; : quad double double ; : double 3 * ; 1 quad 1 double`,
+			[]int{4, 3}, "redefined double "},
+		{"definition using its own old version", `: n 1 ; : n n 1+ ; n`, []int{2}, "redefined n "},
+		{"versions have their own execution tokens",
+			`: d 1 ; ' d : d 2 ; ' d over over = rot execute rot execute`,
+			[]int{0, 1, 2}, "redefined d "},
+		{"built-in word", `: dup 7 ; 1 dup`, []int{1, 7}, "redefined dup "},
+		{"variable", `variable v : v 5 ; v`, []int{5}, "redefined v "},
+		{"built-in word in another case", `: Dup 7 ; 1 dup DUP`, []int{1, 7, 7}, "redefined Dup "},
+		{"used in another case", `: dup 7 ; 1 DUP`, []int{1, 7}, "redefined dup "},
+		{"word in another case", `: sq dup * ; : SQ 1 ; 3 sq`, []int{3, 1}, "redefined SQ "},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		vm := New(&out, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+			if out.String() != tc.output {
+				t.Fatalf("wrong output. expected=%q, got=%q", tc.output, out.String())
+			}
+		})
+	}
+}
+
+func TestCompileWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"brackets and literal", `: f [ 2 3 * ] literal ; f`, []int{6}},
+		{"state", `: st state @ ; immediate : g st literal ; g state @`, []int{-1, 0}},
+		{"state in a definition", `: f state @ ; f`, []int{0}},
+		{"state after ]", `: f [ state @ ] literal ; f`, []int{0}},
+		{"immediate", `: five 5 ; immediate : g five literal 1+ ; g`, []int{6}},
+		{"postpone immediate built-in", `: my-if postpone if ; immediate : my-then postpone then ; immediate
+			: g my-if 1 else 2 my-then ; -1 g 0 g`, []int{1, 2}},
+		{"postpone built-in", `: compile-dup postpone dup ; immediate : g compile-dup * ; 3 g`, []int{9}},
+		{"postpone definition", `: sq dup * ; : csq postpone sq ; immediate : g csq ; 4 g`, []int{16}},
+		{"postpone immediate definition", `: five 5 ; immediate : call-five postpone five ; : g [ call-five ] literal ; g`, []int{5}},
+		{"user-defined control flow", `: unless postpone 0= postpone if ; immediate : g unless 1 then ; 0 g 5 g`, []int{1}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDefinitionSpansLines(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil)
+	for _, line := range []string{`: f`, `1 if 2`, `then ;`, `f`} {
+		if err := vm.Interpret(line); err != nil {
+			t.Fatalf("unexpected error on %q: %v", line, err)
+		}
+	}
+	if got := vm.Stack(); !slices.Equal(got, []int{2}) {
+		t.Fatalf("wrong evaluation. expected=%v, got=%v", []int{2}, got)
+	}
+}
+
+func TestCompileWordErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"literal outside a definition", `1 literal`, "literal: compile-only word"},
+		{"] outside a definition", `]`, "]: compile-only word"},
+		{"postpone without a name", `: f postpone`, "f: postpone: missing name"},
+		{"postpone undefined word", `: f postpone nope ;`, "f: nope: undefined word"},
+		{"literal on empty stack", `: f literal ;`, "f: literal: stack underflow"},
+		{"immediate before any definition", `immediate`, "immediate: no definition"},
+		{"nested definition", `: f : g ;`, "f: :: nested definition"},
+		{"definition inside brackets", `: a 1 [ : b 2 ; ] 3 ;`, "a: :: nested definition"},
+		{"postponed word run outside a definition", `: c postpone dup ; c`, "dup: compile-only word"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestExecutionTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"built-in word", `3 ' dup execute`, []int{3, 3}},
+		{"colon definition", `: sq dup * ; 4 ' sq execute`, []int{16}},
+		{"bracket tick", `: sq dup * ; : apply ['] sq execute ; 5 apply`, []int{25}},
+		{"callback", `: twice dup rot swap execute swap execute ; 3 ' 1+ twice`, []int{5}},
+		{"stored in a variable", `variable op ' + op ! 2 3 op @ execute`, []int{5}},
+		{"stored in a table", `create ops ' + , ' * , : run cells ops + @ execute ; 3 4 0 run 3 4 1 run`, []int{7, 12}},
+		{"identity", `: a ; : b ; ' dup ' DUP = ' a ' b =`, []int{-1, 0}},
+		{"compile,", `: inline-sq ['] dup compile, ['] * compile, ; immediate : g inline-sq ; 6 g`, []int{36}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeferredWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"is", `defer greet : hi 1 ; ' hi is greet greet`, []int{1}},
+		{"bound after use", `defer hook : run hook hook ; : one 1 ; ' one is hook run`, []int{1, 1}},
+		{"rebound", `defer h : r h ; ' dup is h 2 r ' drop is h r`, []int{2}},
+		{"mutual recursion", `defer odd?
+			: even? dup 0= if drop -1 else 1- odd? then ;
+			: (odd?) dup 0= if drop 0 else 1- even? then ;
+			' (odd?) is odd? 4 even? 3 even? 3 odd?`, []int{-1, 0, -1}},
+		{"action-of", `defer h ' dup is h action-of h ' dup =`, []int{-1}},
+		{"defer@ and defer!", `defer h ' + ' h defer! 1 2 h ' h defer@ ' + =`, []int{3, -1}},
+		{"is in a definition", `defer h : set-h ['] 1+ is h ; set-h 5 h`, []int{6}},
+		{"action-of in a definition", `defer h ' dup is h : get action-of h ; get ' dup =`, []int{-1}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeferredWordErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"not set", `defer h h`, "h: deferred word not set"},
+		{"not deferred", `: f ; ' dup is f`, "f: not a deferred word"},
+		{"invalid token", `defer h 0 is h`, "is: invalid execution token 0"},
+		{"empty stack", `defer h is h`, "is: stack underflow"},
+		{"defer@ of a colon definition", `' dup defer@`, "defer@: dup: not a deferred word"},
+		{"defer inside a definition", `: f defer h ;`, "f: defer: interpret-only word"},
+		{"exit outside a definition", `exit`, "exit: compile-only word"},
+		{"recurse outside a definition", `recurse`, "recurse: compile-only word"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSee(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"colon definition", `: sq dup * ; see sq`, ": sq dup * ;\n"},
+		{"calls", `: a ; : b a 1 ; see b`, ": b a 1 ;\n"},
+		{"if else then", `: sign 0< if -1 else 1 then ; see sign`, ": sign 0< if -1 else 1 then ;\n"},
+		{"nested if with empty else", `: f if if 1 else then then ; see f`, ": f if if 1 else then then ;\n"},
+		{"loops", `: f begin dup while 1- repeat begin 1+ dup 5 = until begin again ; see f`,
+			": f begin dup while 1- repeat begin 1+ dup 5 = until begin again ;\n"},
+		{"loop in an if", `: f if begin 1- dup 0= until then ; see f`, ": f if begin 1- dup 0= until then ;\n"},
+		{"recurse and exit", `: f dup 0= if exit then 1- recurse ; see f`, ": f dup 0= if exit then 1- recurse ;\n"},
+		{"compiled literal", `: f [ 2 3 * ] literal ; see f`, ": f 6 ;\n"},
+		{"strings", `: f ." hi" s" x" type ; see f`, `: f ." hi" s" x" type ;` + "\n"},
+		{"number and counted string literals", `: f 1. 2e c" x" ; see f`, `: f 1. 2e c" x" ;` + "\n"},
+		{"execution tokens", `defer h : f ['] dup is h action-of h ; see f`, ": f ['] dup is h action-of h ;\n"},
+		{"immediate and postpone", `: f postpone if ; immediate see f`, ": f postpone if ; immediate\n"},
+		{"postponed immediate word", `: a 1 ; immediate : b postpone a ; see b`, ": b postpone a ;\n"},
+		{"built-in word", `see dup`, "dup is a built-in word\n"},
+		{"variable", `variable v see v`, "variable v\n"},
+		{"deferred word", `defer h ' dup is h see h`, "defer h\n' dup is h\n"},
+		{"marker", `marker m see m`, "marker m\n"},
+	}
+	for _, tc := range tests {
+		for _, big := range []bool{false, true} {
+			var opts []Option
+			name := tc.name
+			if big {
+				opts = append(opts, BigCells())
+				name += " with big cells"
+			}
+			var out bytes.Buffer
+			err := New(&out, nil, opts...).Interpret(tc.input)
+
+			t.Run(name, func(t *testing.T) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if out.String() != tc.expected {
+					t.Fatalf("wrong output. expected=%q, got=%q", tc.expected, out.String())
+				}
+			})
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	var out bytes.Buffer
+	if err := New(&out, nil).Interpret(`: a ; : b ; : a ; : dup ; words`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := strings.TrimPrefix(out.String(), "redefined a redefined dup ")
+	if !strings.HasPrefix(got, "dup a b ! ") || !strings.HasSuffix(got, "\n") {
+		t.Fatalf("wrong output: %q", got)
+	}
+	names := strings.Fields(got)
+	for _, name := range []string{"words", "see", "+", "xor"} {
+		if !slices.Contains(names, name) {
+			t.Fatalf("%s missing from %q", name, got)
+		}
+	}
+	for _, name := range []string{"dup", "a"} {
+		if i := slices.Index(names, name); slices.Contains(names[i+1:], name) {
+			t.Fatalf("%s listed twice: %q", name, got)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"count", `c" abc" count swap drop`, []int{3}},
+		{"built-in word", `c" dup" find swap ' dup =`, []int{-1, -1}},
+		{"immediate built-in word", `c" if" find swap drop`, []int{1}},
+		{"colon definition", `: sq dup * ; 3 c" sq" find drop execute`, []int{9}},
+		{"immediate definition", `: f ; immediate c" f" find swap drop`, []int{1}},
+		{"not found", `c" nope" dup find swap rot =`, []int{0, -1}},
+		{"search-wordlist", `: sq dup * ; 3 s" sq" forth-wordlist search-wordlist drop execute`, []int{9}},
+		{"search-wordlist ignores case of built-in words", `s" DUP" forth-wordlist search-wordlist swap drop`, []int{-1}},
+		{"search-wordlist not found", `s" nope" forth-wordlist search-wordlist`, []int{0}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIntrospectionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"see undefined word", `see nope`, "nope: undefined word"},
+		{"see inside a definition", `: f see f ;`, "f: see: interpret-only word"},
+		{"invalid wordlist", `s" dup" 7 search-wordlist`, "search-wordlist: invalid wordlist 7"},
+		{"find outside data space", `99999 find`, "find: invalid address 99999"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestForgetAndMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"marker restores data space", `here marker m 10 allot 5 , m here =`, []int{-1}},
+		{"marker uncovers redefined words", `: a 1 ; marker m : a 3 ; m a`, []int{1}},
+		{"marker keeps earlier words", `: a 1 ; marker m : b 2 ; m ' a execute`, []int{1}},
+		{"forget", `: a 1 ; : b 2 ; : c 3 ; forget b a`, []int{1}},
+		{"forget uncovers the previous version", `: a 1 ; : a 2 ; forget a a`, []int{1}},
+		{"forget a variable", `here variable v 7 v ! forget v here =`, []int{-1}},
+		{"forget an empty definition", `: a ; : b ; forget a 1`, []int{1}},
+		{"forgotten built-in execution tokens are remade", `marker m ' dup drop m 3 ' dup execute`, []int{3, 3}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestForgetAndMarkerErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"marker forgets later words", `marker m : b 2 ; m b`, "b: undefined word"},
+		{"marker forgets itself", `marker m m m`, "m: undefined word"},
+		{"forget forgets later words", `: a 1 ; : b 2 ; forget a b`, "b: undefined word"},
+		{"deferred action forgotten", `defer h marker m : f 1 ; ' f is h m h`, "h: deferred word not set"},
+		{"forget a built-in word", `forget dup`, "dup: cannot forget a built-in word"},
+		{"forget without a name", `forget`, "forget: missing name"},
+		{"forget inside a definition", `: f forget f ;`, "f: forget: interpret-only word"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestSearchOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"default order", `get-order get-current forth-wordlist`, []int{1, 1, 1, 1}},
+		{"private helper words", `variable priv wordlist priv !
+			get-order priv @ swap 1+ set-order
+			priv @ set-current : helper 2 * ;
+			forth-wordlist set-current : public helper 1+ ;
+			previous 3 public`, []int{7}},
+		{"same name in two wordlists", `variable p wordlist p ! : w 1 ;
+			p @ set-current : w 2 ; forth-wordlist set-current
+			w get-order p @ swap 1+ set-order w previous w`, []int{1, 2, 1}},
+		{"definitions", `wordlist dup 1 set-order definitions get-current =`, []int{-1}},
+		{"also", `also get-order`, []int{1, 1, 2}},
+		{"only", `wordlist 1 set-order only get-order`, []int{1, 1}},
+		{"forth", `wordlist 1 set-order forth get-order`, []int{1, 1}},
+		{"minimum search order", `wordlist 1 set-order -1 set-order get-order`, []int{1, 1}},
+		{"built-in words are always found", `previous 1 dup get-order`, []int{1, 1, 0}},
+		{"search-wordlist", `variable p wordlist p ! p @ set-current : h 5 ; forth-wordlist set-current
+			s" h" p @ search-wordlist drop execute s" h" forth-wordlist search-wordlist`, []int{5, 0}},
+		{"marker restores the search order", `marker m wordlist set-current also m get-order get-current`, []int{1, 1, 1}},
+	}
+	for _, tc := range tests {
+		var out bytes.Buffer
+		vm := New(&out, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+			if out.Len() != 0 {
+				t.Fatalf("unexpected output %q", out.String())
+			}
+		})
+	}
+}
+
+func TestSearchOrderWords(t *testing.T) {
+	var out bytes.Buffer
+	err := New(&out, nil).Interpret(`: a ; wordlist dup set-current also 1 set-order definitions : b ; : c ; words`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "c b\n" {
+		t.Fatalf("wrong output. expected=%q, got=%q", "c b\n", out.String())
+	}
+}
+
+func TestSearchOrderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"previous on an empty order", `previous previous`, "previous: search order is empty"},
+		{"also on an empty order", `0 set-order also`, "also: search order is empty"},
+		{"invalid compilation wordlist", `99 set-current`, "set-current: invalid wordlist 99"},
+		{"invalid wordlist in the order", `99 1 set-order`, "set-order: invalid wordlist 99"},
+		{"count deeper than the stack", `-1 1 rshift set-order`, "set-order: stack underflow"},
+		{"negative count", `-2 set-order`, "set-order: invalid count -2"},
+		{"word hidden by the search order", `: a 1 ; 0 set-order a`, "a: undefined word"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestInclude(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib/a.fs":  "include b.fs\n: sq\n  dup * ;\n",
+		"lib/b.fs":  ": two 2 ;",
+		"lib/c.fs":  "1",
+		"cycle1.fs": "include cycle2.fs",
+		"cycle2.fs": "include cycle1.fs",
+		"req1.fs":   "require req2.fs : one 1 ;",
+		"req2.fs":   "require req1.fs : two 2 ;",
+		"self.fs":   "s\" self.fs\" included",
+		"bad.fs":    "1\nnope\n",
+		"nested.fs": "include bad.fs",
+	}
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		main     string
+		expected []int
+		err      string
+	}{
+		{"include", `include lib/a.fs two sq`, []int{4}, ""},
+		{"included", `s" lib/b.fs" included two`, []int{2}, ""},
+		{"require loads once", `require lib/c.fs require lib/c.fs`, []int{1}, ""},
+		{"required loads once", `s" lib/c.fs" required s" lib/c.fs" required`, []int{1}, ""},
+		{"include after require", `require lib/c.fs include lib/c.fs`, []int{1, 1}, ""},
+		{"require after include", `include lib/c.fs require lib/c.fs`, []int{1}, ""},
+		{"mutual require", `require req1.fs one two`, []int{1, 2}, ""},
+		{"include cycle", `include cycle1.fs`, nil, "cycle1.fs: include cycle"},
+		{"file including itself", `include self.fs`, nil, "self.fs: include cycle"},
+		{"missing file", `include nope.fs`, nil, "no such file or directory"},
+		{"error position", `include nested.fs`, nil, "bad.fs:2:1: nope: undefined word"},
+		{"include inside a definition", `: f include lib/c.fs ;`, nil, "include: interpret-only word"},
+	}
+	for _, tc := range tests {
+		main := filepath.Join(dir, "main.fs")
+		if err := os.WriteFile(main, []byte(tc.main), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Include(main)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.err != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tc.err) {
+					t.Fatalf("wrong error. expected=...%q, got=%v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := vm.Stack(); !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"evaluate", `s" 1 2 +" evaluate`, []int{3}},
+		{"definition", `s" : sq dup * ;" evaluate 3 sq`, []int{9}},
+		{"at run time", `: run evaluate ; s" 4 5 *" run`, []int{20}},
+		{"interpreting while compiling", `: f [ s" 1 2 +" evaluate ] literal ; f`, []int{3}},
+		{"compiling", `: compile-sq s" dup *" evaluate ; immediate : sq compile-sq ; 6 sq`, []int{36}},
+		{"parsing words", `s" defer h ' dup is h" evaluate 7 h`, []int{7, 7}},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		err := vm.Interpret(tc.input)
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"undefined word", `s" 1 nope" evaluate`, "evaluate:1:3: nope: undefined word"},
+		{"second line", "s\" 1\n  2 nope\" evaluate", "evaluate:2:5: nope: undefined word"},
+		{"at run time", `: f s" 1 0 /" evaluate ; f`, "evaluate:1:5: /: division by zero"},
+		{"in a definition", `s" : g nope ;" evaluate`, "evaluate:1:5: g: nope: undefined word"},
+		{"outside data space", `99999 1 evaluate`, "evaluate: invalid address 99999"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestEvaluateNesting(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil)
+	err := vm.Interpret(`: f s" f" evaluate ; f`)
+	expected := "evaluate:1:1: evaluate: sources nested too deeply"
+	if !errors.Is(err, errSourceDepth) || err.Error() != expected {
+		t.Fatalf("wrong error. expected=%q, got=%v", expected, err)
+	}
+	if vm.sources != 0 || vm.rs.Len() != 0 {
+		t.Fatalf("nesting not unwound: %d sources, %d frames", vm.sources, vm.rs.Len())
+	}
+
+	if err := vm.Interpret(`: g s" 1" evaluate ; g`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDefine(t *testing.T) {
+	sum := func(vm *VM) error {
+		total := 0
+		for i := 0; i < 3; i++ {
+			n, err := vm.Pop()
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return vm.Push(total)
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected []int
+	}{
+		{"interpreted", `1 2 3 add3`, []int{6}},
+		{"compiled", `: f 10 20 add3 ; 5 f`, []int{35}},
+		{"execution token", `1 1 1 ' add3 execute`, []int{3}},
+		{"deferred", `defer h ' add3 is h 2 2 2 h`, []int{6}},
+	}
+	for _, tc := range tests {
+		for _, big := range []bool{false, true} {
+			var opts []Option
+			if big {
+				opts = append(opts, BigCells())
+			}
+			vm := New(&bytes.Buffer{}, nil, opts...)
+			if err := vm.Define("add3", "( n1 n2 n3 -- n4 )", sum); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err := vm.Interpret(tc.input)
+			got := vm.Stack()
+
+			t.Run(fmt.Sprintf("%s big=%v", tc.name, big), func(t *testing.T) {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !slices.Equal(got, tc.expected) {
+					t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestDefineErrors(t *testing.T) {
+	fail := func(vm *VM) error { return errors.New("boom") }
+	none := func(vm *VM) error { return nil }
+	tests := []struct {
+		name     string
+		effect   string
+		fn       func(*VM) error
+		input    string
+		expected string
+	}{
+		{"error from Go", "( -- )", fail, `w`, "w: boom"},
+		{"too few items", "( n1 n2 -- )", func(vm *VM) error { vm.Pop(); vm.Pop(); return nil }, `1 w`, "w: stack underflow"},
+		{"effect not kept", "( n -- n n )", none, `1 w`, "w: stack effect ( n -- n n ) not kept: depth went from 1 to 1"},
+		{"error inside a definition", "( -- )", fail, `: f 1 w 2 ; f`, "w: boom"},
+	}
+	for _, tc := range tests {
+		vm := New(&bytes.Buffer{}, nil)
+		if err := vm.Define("w", tc.effect, tc.fn); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err := vm.Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+
+	for _, effect := range []string{"( n )", "( a -- b -- c )", ""} {
+		if err := New(&bytes.Buffer{}, nil).Define("w", effect, none); err == nil {
+			t.Fatalf("invalid stack effect %q accepted", effect)
+		}
+	}
+}
+
+func TestDefineSee(t *testing.T) {
+	var out bytes.Buffer
+	vm := New(&out, nil)
+	if err := vm.Define("nop", "( -- )", func(*VM) error { return nil }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vm.Interpret(`see nop`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "nop is a Go word ( -- )\n" {
+		t.Fatalf("wrong output: %q", out.String())
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	var out bytes.Buffer
+	vm := New(&out, nil)
+	if err := vm.Eval(": sq dup * ;\n: divmod /mod swap ;\n"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := vm.Push(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		word     string
+		args     []int
+		expected []int
+	}{
+		{"sq", []int{7}, []int{49}},
+		{"divmod", []int{7, 2}, []int{3, 1}},
+		{"+", []int{2, 3}, []int{5}},
+		{"DUP", []int{4}, []int{4, 4}},
+		{"emit", []int{'A'}, nil},
+	}
+	for _, tc := range tests {
+		got, err := vm.Call(tc.word, tc.args...)
+
+		t.Run(tc.word, func(t *testing.T) {
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong results. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+	if got := vm.Stack(); !slices.Equal(got, []int{1}) {
+		t.Fatalf("Call changed the stack below its arguments: %v", got)
+	}
+	if out.String() != "A" {
+		t.Fatalf("wrong output. expected=%q, got=%q", "A", out.String())
+	}
+
+	if got, err := vm.Call("drop"); err != nil || len(got) != 0 {
+		t.Fatalf("wrong results of drop: %v, %v", got, err)
+	}
+	if n, err := vm.Pop(); !errors.Is(err, stack.ErrUnderflow) {
+		t.Fatalf("expected underflow, got %d, %v", n, err)
+	}
+}
+
+func TestEmbeddingBigCells(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil, BigCells())
+	got, err := vm.Call("*", 3, 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(got, []int{12}) {
+		t.Fatalf("wrong results. expected=%v, got=%v", []int{12}, got)
+	}
+}
+
+func TestEmbeddingErrors(t *testing.T) {
+	vm := New(&bytes.Buffer{}, nil)
+	if err := vm.Eval(": sq dup * ;\n: bad 1 dup 0 / ;"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		name     string
+		run      func() error
+		expected string
+		stack    []int
+	}{
+		{"undefined word", func() error { _, err := vm.Call("nope"); return err }, "nope: undefined word", []int{}},
+		{"underflow", func() error { _, err := vm.Call("sq"); return err }, "dup: stack underflow", []int{}},
+		{"compile-only word", func() error { _, err := vm.Call("if", 1); return err }, "if: compile-only word", []int{}},
+		{"failing word", func() error { _, err := vm.Call("bad", 10, 20); return err }, "/: division by zero", []int{}},
+		{"eval position", func() error { return vm.Eval("1\n 2 nope") }, "eval:2:4: nope: undefined word", []int{1, 2}},
+	}
+	for _, tc := range tests {
+		err := tc.run()
+		got := vm.Stack()
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+			if !slices.Equal(got, tc.stack) {
+				t.Fatalf("wrong stack after the error. expected=%v, got=%v", tc.stack, got)
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	tests := []struct {
+		name     string
+		bits     int
+		input    string
+		expected []int
+	}{
+		{"variable", 64, `variable x 42 x ! x @`, []int{42}},
+		{"comma", 64, `here 7 , -8 , dup @ swap 1 cells + @`, []int{7, -8}},
+		{"cells", 64, `3 cells`, []int{24}},
+		{"16-bit cells", 16, `2 cells`, []int{4}},
+		{"16-bit variable", 16, `variable v -1 v ! v @ 65535 v ! v @`, []int{-1, -1}},
+		{"create", 64, `create x 1 , 2 , x @ x 1 cells + @`, []int{1, 2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.bits > strconv.IntSize {
+				t.Skip("cell width wider than int")
+			}
+			vm := New(&bytes.Buffer{}, nil, CellWidth(tc.bits))
+			err := vm.Interpret(tc.input)
+			got := vm.Stack()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Fatalf("wrong evaluation. expected=%v, got=%v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestExecutionTokenErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"undefined word", `' nope`, "nope: undefined word"},
+		{"missing name", `'`, "': missing name"},
+		{"invalid token", `5 execute`, "execute: invalid execution token 5"},
+		{"bracket tick outside a definition", `['] dup`, "[']: compile-only word"},
+		{"tick inside a definition", `: f ' dup ;`, "f: ': interpret-only word"},
+		{"compile, outside a definition", `' dup compile,`, "compile,: compile-only word"},
+		{"fetch outside data space", `99999 @`, "@: invalid address 99999"},
+	}
+	for _, tc := range tests {
+		err := New(&bytes.Buffer{}, nil).Interpret(tc.input)
+
+		t.Run(tc.name, func(t *testing.T) {
+			if err == nil || err.Error() != tc.expected {
+				t.Fatalf("wrong error. expected=%q, got=%v", tc.expected, err)
+			}
+		})
+	}
+}
+
+// lex returns every token of input up to, but not including, EOF.
+func lex(input string) []word.Word {
+	l := lexer.New(input, map[word.Word][]word.Word{})
+	var tokens []word.Word
+	for tok := l.NextToken(); tok.Type != word.EOF; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
@@ -0,0 +1,100 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Jorghy-Del/gorth/lexer"
+)
+
+var (
+	errIncludeCycle = errors.New("include cycle")
+	errSourceDepth  = errors.New("sources nested too deeply")
+)
+
+// maxSourceDepth limits how deeply EVALUATE and the words that include
+// files may nest.
+const maxSourceDepth = 256
+
+// Include interprets the Forth source file at path. Files it includes in
+// turn are found relative to its directory.
+func (vm *VM) Include(path string) error {
+	return vm.include(path, false)
+}
+
+// include interprets the source file at path, which is relative to the
+// file being included, if any. With once, a file that has already been
+// included is skipped.
+func (vm *VM) include(path string, once bool) error {
+	if n := len(vm.includes); n > 0 && !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(vm.includes[n-1]), path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	if once && vm.included[abs] {
+		return nil
+	}
+	if slices.Contains(vm.includes, abs) {
+		return fmt.Errorf("%s: %w", path, errIncludeCycle)
+	}
+	src, err := os.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	vm.included[abs] = true
+
+	vm.includes = append(vm.includes, abs)
+	defer func() {
+		vm.includes = vm.includes[:len(vm.includes)-1]
+	}()
+	return vm.interpretSource(path, string(src))
+}
+
+// interpretSource interprets src, reporting an error at the line and
+// column in src of the word that caused it. name names src in the error.
+// An error already located in a nested source keeps that location alone.
+func (vm *VM) interpretSource(name, src string) error {
+	if vm.sources == maxSourceDepth {
+		return fmt.Errorf("%s: %w", name, errSourceDepth)
+	}
+	vm.sources++
+	defer func() {
+		vm.sources--
+	}()
+
+	l := lexer.New(src, nil)
+	err := vm.interpret(l.NextToken)
+	var se *sourceError
+	if err == nil || errors.As(err, &se) {
+		return err
+	}
+	before := src[:l.Start()]
+	return &sourceError{
+		name: name,
+		line: strings.Count(before, "\n") + 1,
+		col:  l.Start() - strings.LastIndexByte(before, '\n'),
+		err:  err,
+	}
+}
+
+// sourceError is an error caused by the word at a line and column of the
+// source called name.
+type sourceError struct {
+	name      string
+	line, col int
+	err       error
+}
+
+func (e *sourceError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.name, e.line, e.col, e.err)
+}
+
+func (e *sourceError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,76 @@
+package eval
+
+import "io"
+
+// chunk is the result of one read from the source of an asyncReader.
+type chunk struct {
+	b   []byte
+	err error
+}
+
+// asyncReader reads its source in a goroutine of its own, so that ready
+// can tell whether input is waiting without blocking. The goroutine is
+// started by the first read and runs until the source reports an error.
+type asyncReader struct {
+	r       io.Reader
+	ch      chan chunk
+	started bool
+
+	// buf is what is left of the last chunk received, and err the error
+	// that ended the source, once received.
+	buf []byte
+	err error
+}
+
+func newAsyncReader(r io.Reader) *asyncReader {
+	return &asyncReader{r: r, ch: make(chan chunk, 1)}
+}
+
+// start starts the goroutine that reads the source, if it is not running.
+func (a *asyncReader) start() {
+	if a.started {
+		return
+	}
+	a.started = true
+	go func() {
+		for {
+			b := make([]byte, 4096)
+			n, err := a.r.Read(b)
+			a.ch <- chunk{b[:n], err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// receive stores c as the next input to be read.
+func (a *asyncReader) receive(c chunk) {
+	a.buf, a.err = c.b, c.err
+}
+
+func (a *asyncReader) Read(p []byte) (int, error) {
+	a.start()
+	for len(a.buf) == 0 && a.err == nil {
+		a.receive(<-a.ch)
+	}
+	if len(a.buf) > 0 {
+		n := copy(p, a.buf)
+		a.buf = a.buf[n:]
+		return n, nil
+	}
+	return 0, a.err
+}
+
+// ready reports whether Read can return input without waiting for it.
+func (a *asyncReader) ready() bool {
+	a.start()
+	if len(a.buf) == 0 && a.err == nil {
+		select {
+		case c := <-a.ch:
+			a.receive(c)
+		default:
+		}
+	}
+	return len(a.buf) > 0
+}
@@ -0,0 +1,187 @@
+package eval
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/Jorghy-Del/gorth/lexer"
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+// Interpret runs the Forth source src. Words are executed while the VM is
+// interpreting and compiled into the current definition while it is
+// compiling, so a definition may span several calls.
+func (vm *VM) Interpret(src string) error {
+	return vm.interpret(lexer.New(src, nil).NextToken)
+}
+
+// Eval interprets the program src. Unlike Interpret, an error reports the
+// line and column in src of the word that caused it.
+func (vm *VM) Eval(src string) error {
+	return vm.interpretSource("eval", src)
+}
+
+// Call pushes args, runs the word called name and returns the items it
+// left on the parameter stack above those it was called with, removing
+// them from the stack. name is looked up like FIND would. If the word
+// fails, its arguments and anything it left are removed too.
+func (vm *VM) Call(name string, args ...int) ([]int, error) {
+	defer vm.out.Flush()
+
+	def, ok := vm.findName(name)
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", name, errUndefined)
+	}
+	base := vm.cells().Len()
+	for _, n := range args {
+		if err := vm.Push(n); err != nil {
+			vm.dropTo(base)
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if err := vm.run(def); err != nil {
+		vm.dropTo(base)
+		return nil, err
+	}
+
+	var results []int
+	for vm.cells().Len() > base {
+		n, _ := vm.Pop()
+		results = append(results, n)
+	}
+	slices.Reverse(results)
+	return results, nil
+}
+
+// dropTo removes items from the top of the parameter stack until only n
+// are left.
+func (vm *VM) dropTo(n int) {
+	for vm.cells().Len() > n {
+		vm.Pop()
+	}
+}
+
+// interpret is the outer interpreter. It reads words from next until EOF
+// and interprets or compiles each according to STATE. An error abandons
+// the definition being compiled.
+func (vm *VM) interpret(next func() word.Word) error {
+	defer vm.out.Flush()
+
+	for t := next(); t.Type != word.EOF; t = next() {
+		var err error
+		if vm.compiling {
+			err = vm.compileWord(t, next)
+		} else {
+			err = vm.interpretWord(t, next)
+		}
+		if err != nil {
+			if vm.current != nil {
+				err = fmt.Errorf("%s: %w", vm.current.name, err)
+				vm.abandon()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// interpretWord executes t. : reads the name of the new definition from
+// next and starts compiling it.
+func (vm *VM) interpretWord(t word.Word, next func() word.Word) error {
+	if def, ok := vm.lookup(t); ok {
+		return vm.run(def)
+	}
+	switch t.Type {
+	case word.DEFINE:
+		if vm.current != nil {
+			return fmt.Errorf("%s: %w", t.Literal, errNested)
+		}
+		name := next()
+		if name.Type == word.EOF {
+			return fmt.Errorf("%s: missing name", t.Literal)
+		}
+		vm.begin(name.Literal)
+		return nil
+	case word.CREATE, word.VARIABLE:
+		name := next()
+		if name.Type == word.EOF {
+			return fmt.Errorf("%s: missing name", t.Literal)
+		}
+		vm.define(&definition{name: name.Literal, code: []instr{vm.lit(t, len(vm.data))}, here: len(vm.data)})
+		if t.Type == word.VARIABLE {
+			vm.data = append(vm.data, make([]byte, vm.cellSize())...)
+		}
+		return nil
+	case word.DEFER:
+		name := next()
+		if name.Type == word.EOF {
+			return fmt.Errorf("%s: missing name", t.Literal)
+		}
+		def := &definition{name: name.Literal, deferred: true, here: len(vm.data)}
+		def.code = []instr{{op: opDefer, w: name, def: def}}
+		vm.define(def)
+		return nil
+	case word.MARKER:
+		name := next()
+		if name.Type == word.EOF {
+			return fmt.Errorf("%s: missing name", t.Literal)
+		}
+		def := &definition{name: name.Literal, here: len(vm.data), marked: vm.order.clone()}
+		def.code = []instr{{op: opMarker, w: t, def: def}}
+		vm.define(def)
+		return nil
+	case word.FORGET:
+		def, err := vm.find(t, next())
+		if err != nil {
+			return err
+		}
+		if vm.isPrimitive(def) {
+			return fmt.Errorf("%s: cannot forget a built-in word", def.name)
+		}
+		vm.forget(def)
+		return nil
+	case word.IS, word.ACTIONOF:
+		def, err := vm.findDeferred(t, next())
+		if err != nil {
+			return err
+		}
+		if err := vm.push(t, def.xt); err != nil {
+			return err
+		}
+		return vm.step(deferOp(t))
+	case word.INCLUDE, word.REQUIRE:
+		name := next()
+		if name.Type == word.EOF {
+			return fmt.Errorf("%s: missing name", t.Literal)
+		}
+		return vm.include(name.Literal, t.Type == word.REQUIRE)
+	case word.SEE:
+		def, err := vm.find(t, next())
+		if err != nil {
+			return err
+		}
+		vm.see(def)
+		return nil
+	case word.TICK:
+		def, err := vm.find(t, next())
+		if err != nil {
+			return err
+		}
+		return vm.push(t, def.xt)
+	case word.RBRACKET:
+		if vm.current == nil {
+			return fmt.Errorf("%s: %w", t.Literal, errCompileOnly)
+		}
+		vm.setCompiling(true)
+		return nil
+	}
+	return vm.step(t)
+}
+
+// push pushes n on the parameter stack for the word t.
+func (vm *VM) push(t word.Word, n int) error {
+	if err := vm.Push(n); err != nil {
+		return fmt.Errorf("%s: %w", t.Literal, err)
+	}
+	return nil
+}
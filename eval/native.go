@@ -0,0 +1,53 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jorghy-Del/gorth/stack"
+)
+
+// native is a word implemented in Go by the host program.
+type native struct {
+	fn      func(*VM) error
+	effect  string
+	in, out int
+}
+
+// Define adds a word called name to the compilation wordlist that runs fn.
+// effect is the word's stack effect in Forth notation, such as
+// "( n1 n2 -- n3 )". Before calling fn the VM checks that the parameter
+// stack holds the items named before "--", and afterwards that fn replaced
+// them with as many items as are named after it. fn uses Push and Pop to
+// reach the stack; an error it returns stops the program.
+func (vm *VM) Define(name, effect string, fn func(*VM) error) error {
+	body := strings.TrimSpace(effect)
+	body = strings.TrimSuffix(strings.TrimPrefix(body, "("), ")")
+	before, after, ok := strings.Cut(body, "--")
+	if !ok || strings.Contains(after, "--") {
+		return fmt.Errorf("%s: invalid stack effect %q", name, effect)
+	}
+
+	n := &native{fn: fn, effect: effect, in: len(strings.Fields(before)), out: len(strings.Fields(after))}
+	def := &definition{name: name, here: len(vm.data)}
+	def.code = []instr{{op: opNative, def: def}}
+	def.native = n
+	vm.define(def)
+	return nil
+}
+
+// callNative runs the Go word def, checking its declared stack effect.
+func (vm *VM) callNative(def *definition) error {
+	n := def.native
+	depth := vm.cells().Len()
+	if depth < n.in {
+		return fmt.Errorf("%s: %w", def.name, stack.ErrUnderflow)
+	}
+	if err := n.fn(vm); err != nil {
+		return fmt.Errorf("%s: %w", def.name, err)
+	}
+	if got := vm.cells().Len(); got != depth-n.in+n.out {
+		return fmt.Errorf("%s: stack effect %s not kept: depth went from %d to %d", def.name, n.effect, depth, got)
+	}
+	return nil
+}
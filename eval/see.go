@@ -0,0 +1,133 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+// see prints def as Forth source.
+func (vm *VM) see(def *definition) {
+	switch {
+	case def.deferred:
+		fmt.Fprintf(vm.out, "defer %s\n", def.name)
+		if def.action != 0 {
+			fmt.Fprintf(vm.out, "' %s is %s\n", vm.xts[def.action-1].name, def.name)
+		}
+		return
+	case def.native != nil:
+		fmt.Fprintf(vm.out, "%s is a Go word %s\n", def.name, def.native.effect)
+		return
+	case vm.isPrimitive(def):
+		fmt.Fprintf(vm.out, "%s is a built-in word\n", def.name)
+		return
+	case len(def.code) == 1 && (def.code[0].w.Type == word.CREATE || def.code[0].w.Type == word.VARIABLE ||
+		def.code[0].w.Type == word.MARKER):
+		fmt.Fprintf(vm.out, "%s %s\n", strings.ToLower(def.code[0].w.Literal), def.name)
+		return
+	}
+
+	src := append([]string{":", def.name}, vm.decompile(def.code)...)
+	src = append(src, ";")
+	if def.immediate {
+		src = append(src, "immediate")
+	}
+	fmt.Fprintln(vm.out, strings.Join(src, " "))
+}
+
+// decompile turns threaded code back into the words that compiled it.
+// THEN and BEGIN leave no instruction of their own, so they are recovered
+// from the targets of the branches that refer to them.
+func (vm *VM) decompile(code []instr) []string {
+	begins := make([]int, len(code)+1)
+	for i, ins := range code {
+		if (ins.op == opBranch || ins.op == opBranch0) && ins.n < 0 {
+			begins[i+1+ins.n]++
+		}
+	}
+
+	// open holds the forward branches not yet resolved, innermost last.
+	var open []orig
+	var src []string
+	for i := 0; i <= len(code); i++ {
+		for len(open) > 0 && open[len(open)-1].at == i {
+			if open[len(open)-1].kind != word.WHILE {
+				src = append(src, "then")
+			}
+			open = open[:len(open)-1]
+		}
+		if i == len(code) {
+			break
+		}
+		for ; begins[i] > 0; begins[i]-- {
+			src = append(src, "begin")
+		}
+
+		ins := code[i]
+		switch ins.op {
+		case opBranch, opBranch0:
+			if ins.n >= 0 {
+				if ins.w.Type == word.ELSE && len(open) > 0 {
+					open = open[:len(open)-1]
+				}
+				open = append(open, orig{ins.w.Type, i + 1 + ins.n})
+			}
+			src = append(src, strings.ToLower(ins.w.Literal))
+		case opLit:
+			switch ins.w.Type {
+			case word.BRACKETTICK:
+				src = append(src, "[']", vm.xts[xt(ins)-1].name)
+			case word.IS, word.ACTIONOF:
+				src = append(src, strings.ToLower(ins.w.Literal), vm.xts[xt(ins)-1].name)
+				i++ // skip the DEFER! or DEFER@ that follows
+			case word.DOUBLE:
+				src = append(src, ins.w.Literal)
+				i++ // skip the high cell
+			case word.STRING:
+				src = append(src, `s"`, ins.w.Literal+`"`)
+				i++ // skip the length
+			case word.CSTRING:
+				src = append(src, `c"`, ins.w.Literal+`"`)
+			default:
+				if ins.b != nil {
+					src = append(src, ins.b.String())
+				} else {
+					src = append(src, fmt.Sprint(ins.n))
+				}
+			}
+		case opCall, opTail:
+			switch {
+			case ins.w.Type == word.RECURSE:
+				src = append(src, "recurse")
+			case ins.def.immediate:
+				// Immediate words run when they appear in a definition, so a
+				// call to one must have been compiled by POSTPONE.
+				src = append(src, "postpone", ins.def.name)
+			default:
+				src = append(src, ins.def.name)
+			}
+		case opCompile:
+			src = append(src, "postpone", ins.w.Literal)
+		case opPrim:
+			switch ins.w.Type {
+			case word.DOTQUOTE:
+				src = append(src, `."`, ins.w.Literal+`"`)
+			default:
+				src = append(src, ins.w.Literal)
+			}
+		default:
+			src = append(src, ins.w.Literal)
+		}
+	}
+	return src
+}
+
+// xt returns the execution token pushed by the literal ins, which holds it
+// in b when cells are arbitrary-precision.
+func xt(ins instr) int {
+	if ins.b != nil {
+		return int(ins.b.Int64())
+	}
+	return ins.n
+}
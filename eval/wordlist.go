@@ -0,0 +1,109 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Jorghy-Del/gorth/word"
+)
+
+// forthWordlist is the identifier of the wordlist that the built-in words
+// and, by default, new definitions belong to.
+const forthWordlist = 1
+
+var errEmptyOrder = errors.New("search order is empty")
+
+// searchOrder is the list of wordlists searched for words, first searched
+// first, and the compilation wordlist new definitions are added to.
+type searchOrder struct {
+	wids        []int
+	compilation int
+}
+
+// only sets the minimum search order, which holds just the Forth wordlist.
+func (o *searchOrder) only() {
+	o.wids = []int{forthWordlist}
+	if o.compilation == 0 {
+		o.compilation = forthWordlist
+	}
+}
+
+func (o *searchOrder) clone() *searchOrder {
+	return &searchOrder{wids: slices.Clone(o.wids), compilation: o.compilation}
+}
+
+// key returns the key under which the word called name is kept in a
+// wordlist. Names are case-insensitive, as those of built-in words are.
+func key(name string) string {
+	return strings.ToLower(name)
+}
+
+// newWordlist creates an empty wordlist and returns its identifier.
+func (vm *VM) newWordlist() int {
+	vm.wordlists = append(vm.wordlists, map[string]*definition{})
+	return len(vm.wordlists)
+}
+
+// checkWordlist reports an error if wid does not identify a wordlist.
+func (vm *VM) checkWordlist(wid int) error {
+	if wid < 1 || wid > len(vm.wordlists) {
+		return fmt.Errorf("invalid wordlist %d", wid)
+	}
+	return nil
+}
+
+// search finds the colon definition called name in the wordlists of the
+// search order. Built-in words are not searched.
+func (vm *VM) search(name string) (*definition, bool) {
+	for _, wid := range vm.order.wids {
+		if def, ok := vm.wordlists[wid-1][key(name)]; ok {
+			return def, true
+		}
+	}
+	return nil, false
+}
+
+// searchWordlist finds the word called name in the wordlist wid, which must
+// be valid. The Forth wordlist also holds the built-in words.
+func (vm *VM) searchWordlist(wid int, name string) (*definition, bool) {
+	if def, ok := vm.wordlists[wid-1][key(name)]; ok {
+		return def, true
+	}
+	if wid == forthWordlist {
+		return vm.builtin(name)
+	}
+	return nil, false
+}
+
+// words prints the names of the words in the first wordlist of the search
+// order, latest first, followed by the built-in words in alphabetical
+// order if it is the Forth wordlist.
+func (vm *VM) words() {
+	if len(vm.order.wids) == 0 {
+		fmt.Fprintln(vm.out)
+		return
+	}
+	wid := vm.order.wids[0]
+	list := vm.wordlists[wid-1]
+
+	var names []string
+	for i := len(vm.xts) - 1; i >= 0; i-- {
+		def := vm.xts[i]
+		if list[key(def.name)] == def {
+			names = append(names, def.name)
+		}
+	}
+	if wid == forthWordlist {
+		var builtins []string
+		for name := range word.Table {
+			if _, ok := list[name]; !ok {
+				builtins = append(builtins, name)
+			}
+		}
+		slices.Sort(builtins)
+		names = append(names, builtins...)
+	}
+	fmt.Fprintln(vm.out, strings.Join(names, " "))
+}
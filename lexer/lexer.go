@@ -1,6 +1,9 @@
 package lexer
 
 import (
+	"strconv"
+	"strings"
+
 	"github.com/Jorghy-Del/gorth/word"
 )
 
@@ -9,6 +12,7 @@ type Lexer struct {
 	ch           byte
 	position     int
 	readPosition int
+	start        int
 	Dictionary   map[word.Word][]word.Word
 }
 
@@ -39,50 +43,57 @@ func (l *Lexer) peekChar() byte {
 	}
 }
 
+// NextToken returns the next whitespace-delimited word of the input. Words
+// made up of an optional minus sign followed by digits are INTs, or DOUBLEs
+// when followed by a '.'. Numbers with an exponent, such as 1.5e0 or 2e,
+// are FLOATs. .", s" and c" take the text up to the closing '"' as their
+// literal.
 func (l *Lexer) NextToken() (tok word.Word) {
 	l.skipWhitespace()
+	l.start = l.position
+	if l.ch == 0x00 {
+		return newToken(word.EOF, "0x00")
+	}
 
-	switch l.ch {
-	case '-':
-		p := l.peekChar()
-		if isDigit(p) {
-			tok.Type = word.INT
-			l.readChar()
-			tok.Literal = "-" + l.readNumber()
-			return tok
-		} else {
-			w := string(l.ch)
-			tok = newToken(word.GetWordType(w, l.Dictionary), w)
-		}
-	case ':', ';', '.', '+', '*', '/', '%', '<', '>', '=':
-		w := string(l.ch)
-		tok = newToken(word.GetWordType(w, l.Dictionary), w)
-	case 0x00:
-		tok = newToken(word.EOF, "0x00")
+	w := l.readWord()
+	switch {
+	case w == `."`:
+		tok = newToken(word.DOTQUOTE, l.readQuoted())
+	case w == `s"` || w == `S"`:
+		tok = newToken(word.STRING, l.readQuoted())
+	case w == `c"` || w == `C"`:
+		tok = newToken(word.CSTRING, l.readQuoted())
+	case isNumber(w):
+		tok = newToken(word.INT, w)
+	case strings.HasSuffix(w, ".") && isNumber(w[:len(w)-1]):
+		tok = newToken(word.DOUBLE, w)
+	case isFloat(w):
+		tok = newToken(word.FLOAT, w)
 	default:
-		if isLetter(l.ch) {
-			w := l.readString()
-			tok = newToken(word.GetWordType(w, l.Dictionary), w)
-		} else if isDigit(l.ch) {
-			tok.Type = word.INT
-			tok.Literal = l.readNumber()
-			return tok
-		} else {
-			tok = newToken(word.ILLEGAL, string(l.ch))
-		}
+		tok = newToken(word.GetWordType(w, l.Dictionary), w)
 	}
-	l.readChar()
 	return tok
 }
 
+// Start returns the offset in the input of the first character of the
+// token last returned by NextToken.
+func (l *Lexer) Start() int {
+	return l.start
+}
+
 func newToken(wT word.WordType, literal string) word.Word {
 	return word.Word{Type: wT, Literal: literal}
 }
 
-func (l *Lexer) DefineWord() {
+// DefineWord reads a colon definition up to its ';', stores its tokens in
+// the dictionary and returns the key they are stored under.
+//
+// Deprecated: the eval package's outer interpreter compiles definitions
+// itself; use VM.Interpret.
+func (l *Lexer) DefineWord() word.Word {
 	l.readChar() // skip ':'
 	l.skipWhitespace()
-	udf := l.readString()
+	udf := l.readWord()
 
 	var definitionStack []word.Word
 	for l.ch != 0x00 {
@@ -94,34 +105,82 @@ func (l *Lexer) DefineWord() {
 	}
 	w := word.Word{Type: word.UDF, Literal: udf}
 	l.Dictionary[w] = definitionStack
+	return w
 }
 
-func (l *Lexer) readString() string {
+// readQuoted reads the text of a ." or s" literal up to the closing '"'.
+// The single space separating the word from its text is skipped.
+func (l *Lexer) readQuoted() string {
+	if l.ch == ' ' {
+		l.readChar()
+	}
 	start := l.position
-	for isLetter(l.ch) {
+	for l.ch != '"' && l.ch != 0x00 {
 		l.readChar()
 	}
-	return l.input[start:l.position]
+	text := l.input[start:l.position]
+	l.readChar() // skip '"'
+	return text
 }
 
-func isLetter(ch byte) bool {
-	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch == '?'
-}
-
-func (l *Lexer) readNumber() string {
+func (l *Lexer) readWord() string {
 	start := l.position
-	for isDigit(l.ch) {
+	for l.ch != 0x00 && !isWhitespace(l.ch) {
 		l.readChar()
 	}
 	return l.input[start:l.position]
 }
 
+func isNumber(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFloat reports whether s is a floating-point literal: a significand
+// followed by E or e and an optionally empty exponent.
+func isFloat(s string) bool {
+	if strings.Trim(s, "0123456789+-.eE") != "" {
+		return false
+	}
+	i := strings.IndexAny(s, "eE")
+	if i < 0 {
+		return false
+	}
+	significand := strings.TrimPrefix(strings.TrimPrefix(s[:i], "-"), "+")
+	if significand == "" || !isDigit(significand[0]) {
+		return false
+	}
+	_, err := strconv.ParseFloat(expandFloat(s), 64)
+	return err == nil
+}
+
+// expandFloat returns the float literal s in a form accepted by
+// strconv.ParseFloat, supplying the exponent Forth allows to be left out.
+func expandFloat(s string) string {
+	if strings.HasSuffix(s, "e") || strings.HasSuffix(s, "E") {
+		return s + "0"
+	}
+	return s
+}
+
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\n' || ch == '\t' || ch == '\r'
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\n' || l.ch == '\t' || l.ch == '\r' || l.ch == 0xd || l.ch == 0xa {
+	for isWhitespace(l.ch) {
 		l.readChar()
 	}
 }
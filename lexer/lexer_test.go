@@ -201,6 +201,42 @@ func TestNextTokenTable(t *testing.T) {
 				{word.INVERT, "invert", map[word.Word][]word.Word{}},
 			},
 		},
+		{
+			name:       "words are delimited by whitespace",
+			input:      `1+ */mod -x 2/ -5 FM/MOD 123. -4. d. 1.5e0 -2e 3E-2 f. e1 1.5`,
+			dictionary: map[word.Word][]word.Word{},
+			output: []expected{
+				{word.ONEPLUS, "1+", map[word.Word][]word.Word{}},
+				{word.STARSLASHMOD, "*/mod", map[word.Word][]word.Word{}},
+				{word.ILLEGAL, "-x", map[word.Word][]word.Word{}},
+				{word.TWOSLASH, "2/", map[word.Word][]word.Word{}},
+				{word.INT, "-5", map[word.Word][]word.Word{}},
+				{word.FMMOD, "FM/MOD", map[word.Word][]word.Word{}},
+				{word.DOUBLE, "123.", map[word.Word][]word.Word{}},
+				{word.DOUBLE, "-4.", map[word.Word][]word.Word{}},
+				{word.DDOT, "d.", map[word.Word][]word.Word{}},
+				{word.FLOAT, "1.5e0", map[word.Word][]word.Word{}},
+				{word.FLOAT, "-2e", map[word.Word][]word.Word{}},
+				{word.FLOAT, "3E-2", map[word.Word][]word.Word{}},
+				{word.FDOT, "f.", map[word.Word][]word.Word{}},
+				{word.ILLEGAL, "e1", map[word.Word][]word.Word{}},
+				{word.ILLEGAL, "1.5", map[word.Word][]word.Word{}},
+			},
+		},
+		{
+			name:       "string literals",
+			input:      `." hello world" s" forth" c" dup" count type . cr`,
+			dictionary: map[word.Word][]word.Word{},
+			output: []expected{
+				{word.DOTQUOTE, "hello world", map[word.Word][]word.Word{}},
+				{word.STRING, "forth", map[word.Word][]word.Word{}},
+				{word.CSTRING, "dup", map[word.Word][]word.Word{}},
+				{word.COUNT, "count", map[word.Word][]word.Word{}},
+				{word.TYPE, "type", map[word.Word][]word.Word{}},
+				{word.POP, ".", map[word.Word][]word.Word{}},
+				{word.CR, "cr", map[word.Word][]word.Word{}},
+			},
+		},
 		{
 			name:       "udf: double",
 			input:      `: double dup + ;`,
@@ -210,9 +246,9 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.DEFINE,
 					expectedLiteral: ":",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 				},
@@ -227,8 +263,8 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.DEFINE,
 					expectedLiteral: ":",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"}, {word.MULTIPLY, "*"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"}, {Type: word.MULTIPLY, Literal: "*"},
 						},
 					},
 				},
@@ -243,10 +279,10 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.DEFINE,
 					expectedLiteral: ":",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "half"}: []word.Word{
-							{word.INT, "2"},
-							{word.SWAP, "swap"},
-							{word.DIVIDE, "/"},
+						word.Word{Type: word.UDF, Literal: "half"}: []word.Word{
+							{Type: word.INT, Literal: "2"},
+							{Type: word.SWAP, Literal: "swap"},
+							{Type: word.DIVIDE, Literal: "/"},
 						},
 					},
 				},
@@ -266,9 +302,9 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.DEFINE,
 					expectedLiteral: ":",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 				},
@@ -276,9 +312,9 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.INT,
 					expectedLiteral: "10",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 				},
@@ -286,9 +322,9 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.UDF,
 					expectedLiteral: "double",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "double"}: []word.Word{
-							{word.DUP, "dup"},
-							{word.ADD, "+"},
+						word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+							{Type: word.DUP, Literal: "dup"},
+							{Type: word.ADD, Literal: "+"},
 						},
 					},
 				},
@@ -303,14 +339,14 @@ func TestNextTokenTable(t *testing.T) {
 					expectedType:    word.DEFINE,
 					expectedLiteral: ":",
 					expectedDictionary: map[word.Word][]word.Word{
-						word.Word{word.UDF, "buzz?"}: []word.Word{
-							{word.INT, "5"},
-							{word.MOD, "mod"},
-							{word.INT, "0"},
-							{word.EQ, "="},
-							{word.IF, "if"},
-							{word.INT, "2"},
-							{word.THEN, "then"},
+						word.Word{Type: word.UDF, Literal: "buzz?"}: []word.Word{
+							{Type: word.INT, Literal: "5"},
+							{Type: word.MOD, Literal: "mod"},
+							{Type: word.INT, Literal: "0"},
+							{Type: word.EQ, Literal: "="},
+							{Type: word.IF, Literal: "if"},
+							{Type: word.INT, Literal: "2"},
+							{Type: word.THEN, Literal: "then"},
 						},
 					},
 				},
@@ -356,7 +392,7 @@ func TestDefineWord(t *testing.T) {
 			input:      ": myudf",
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "myudf"}: nil,
+				word.Word{Type: word.UDF, Literal: "myudf"}: nil,
 			},
 		},
 		{
@@ -364,7 +400,7 @@ func TestDefineWord(t *testing.T) {
 			input:      ": myword ;",
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "myword"}: nil,
+				word.Word{Type: word.UDF, Literal: "myword"}: nil,
 			},
 		},
 		{
@@ -372,9 +408,9 @@ func TestDefineWord(t *testing.T) {
 			input:      ": double dup + ;",
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "double"}: []word.Word{
-					{word.DUP, "dup"},
-					{word.ADD, "+"},
+				word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+					{Type: word.DUP, Literal: "dup"},
+					{Type: word.ADD, Literal: "+"},
 				},
 			},
 		},
@@ -383,9 +419,9 @@ func TestDefineWord(t *testing.T) {
 			input:      ": square dup * ;",
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "square"}: []word.Word{
-					{word.DUP, "dup"},
-					{word.MULTIPLY, "*"},
+				word.Word{Type: word.UDF, Literal: "square"}: []word.Word{
+					{Type: word.DUP, Literal: "dup"},
+					{Type: word.MULTIPLY, Literal: "*"},
 				},
 			},
 		},
@@ -394,9 +430,9 @@ func TestDefineWord(t *testing.T) {
 			input:      `: double dup + ; 10 double`,
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "double"}: []word.Word{
-					{word.DUP, "dup"},
-					{word.ADD, "+"},
+				word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+					{Type: word.DUP, Literal: "dup"},
+					{Type: word.ADD, Literal: "+"},
 				},
 			},
 		},
@@ -405,9 +441,9 @@ func TestDefineWord(t *testing.T) {
 			input:      `: double dup + ; 10 double`,
 			dictionary: map[word.Word][]word.Word{},
 			expectedDictionary: map[word.Word][]word.Word{
-				word.Word{word.UDF, "double"}: []word.Word{
-					{word.DUP, "dup"},
-					{word.ADD, "+"},
+				word.Word{Type: word.UDF, Literal: "double"}: []word.Word{
+					{Type: word.DUP, Literal: "dup"},
+					{Type: word.ADD, Literal: "+"},
 				},
 			},
 		},
@@ -422,3 +458,15 @@ func TestDefineWord(t *testing.T) {
 		})
 	}
 }
+
+func TestStart(t *testing.T) {
+	input := "  dup\n\t1 .\" hi there\" s>d"
+	expected := []int{2, 7, 9, 22, 25}
+	l := New(input, map[word.Word][]word.Word{})
+	for i, start := range expected {
+		tok := l.NextToken()
+		if l.Start() != start {
+			t.Fatalf("wrong start of token %d %q. expected=%d, got=%d", i, tok.Literal, start, l.Start())
+		}
+	}
+}
@@ -1,57 +1,41 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"bufio"
+	"strconv"
 
 	"github.com/Jorghy-Del/gorth/eval"
-	"github.com/Jorghy-Del/gorth/lexer"
-	"github.com/Jorghy-Del/gorth/word"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal(fmt.Sprintf("usage: %s <filename>", os.Args[0]))
+	bits := flag.Int("bits", 0, "cell width in bits: 16, 32 or 64 (default: width of int)")
+	bigCells := flag.Bool("big", false, "use arbitrary-precision integer cells")
+	rdepth := flag.Int("rdepth", 0, "maximum return stack depth (default 65536)")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		log.Fatal(fmt.Sprintf("usage: %s [-bits n] [-big] [-rdepth n] <filename>", os.Args[0]))
 	}
-	filename := os.Args[1]
+	filename := flag.Arg(0)
 
-	fh, err := os.Open(filename)
-	defer fh.Close()
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	scanner := bufio.NewScanner(fh)
-
-	var words []word.Word
-	dictionary := map[string][]word.Word{}
-	for scanner.Scan() {
-		line := scanner.Text()
-		fmt.Printf("line: %s\n", line)
-		l := lexer.New(line, dictionary)
-
-		for {
-			w := l.NextToken()
-			if w.Type == word.EOF {
-				fmt.Printf("%v\n", w.Literal)
-				break
-			}
-			// for udf, def := range d {
-			// 	if _, ok := dictionary[udf]; !ok {
-			// 		dictionary[udf] = def
-			// 	} else {
-			// 		words = append(words, def...)
-			// 	}
-			// }
-			// words = append(words, w)
-			// if v, ok := dictionary[w.Literal]; ok {
-			// 	words = append(words, v...)
-			// }
+	var opts []eval.Option
+	if *bits != 0 {
+		if *bits != 16 && *bits != 32 && *bits != 64 || *bits > strconv.IntSize {
+			log.Fatal(fmt.Sprintf("unsupported cell width %d", *bits))
 		}
-
-		ParameterStack := eval.Execute(words)
-		fmt.Printf(">>: %v\n\n", ParameterStack)
+		opts = append(opts, eval.CellWidth(*bits))
+	}
+	if *bigCells {
+		opts = append(opts, eval.BigCells())
+	}
+	if *rdepth != 0 {
+		opts = append(opts, eval.ReturnDepth(*rdepth))
+	}
+	vm := eval.New(os.Stdout, os.Stdin, opts...)
+	if err := vm.Include(filename); err != nil {
+		log.Fatal(err)
 	}
+	fmt.Printf(">>: %v\n", vm.Stack())
 }
@@ -1,32 +1,119 @@
 package stack
 
-import "log"
+import "errors"
 
-type Stack struct {
-	Stk []int
+var (
+	// ErrUnderflow is returned when an operation needs more items than the
+	// stack holds. Pop, Top and Second panic with it.
+	ErrUnderflow = errors.New("stack underflow")
+
+	// ErrOverflow is returned when an operation would grow the stack past
+	// its maximum depth. Push panics with it.
+	ErrOverflow = errors.New("stack overflow")
+)
+
+// Stack is a last-in first-out stack. The zero value is an empty stack with
+// no maximum depth.
+type Stack[T any] struct {
+	stk []T
+
+	// Max is the maximum depth of the stack. Zero means no limit.
+	Max int
 }
 
-func (s *Stack) Push(v int) {
-	s.Stk = append(s.Stk, v)
+func (s *Stack[T]) Push(v T) {
+	if err := s.PushE(v); err != nil {
+		panic(err)
+	}
+}
+
+// PushE is like Push but returns ErrOverflow instead of panicking.
+func (s *Stack[T]) PushE(v T) error {
+	if s.Max > 0 && len(s.stk) >= s.Max {
+		return ErrOverflow
+	}
+	s.stk = append(s.stk, v)
+	return nil
 }
 
-func (s *Stack) Pop() (top int) {
-	top = s.Stk[len(s.Stk)-1]
-	s.Stk = s.Stk[:len(s.Stk)-1]
+func (s *Stack[T]) Pop() (top T) {
+	top, err := s.PopE()
+	if err != nil {
+		panic(err)
+	}
 	return top
 }
 
-func (s *Stack) Len() int {
-	return len(s.Stk)
+// PopE is like Pop but returns ErrUnderflow instead of panicking.
+func (s *Stack[T]) PopE() (top T, err error) {
+	if len(s.stk) == 0 {
+		return top, ErrUnderflow
+	}
+	top = s.stk[len(s.stk)-1]
+	s.stk = s.stk[:len(s.stk)-1]
+	return top, nil
+}
+
+func (s *Stack[T]) Len() int {
+	return len(s.stk)
+}
+
+func (s *Stack[T]) Top() T {
+	return s.mustPeek(0)
 }
 
-func (s *Stack) Top() int {
-	if len(s.Stk) < 0 {
-		log.Fatal("len(s.Stk) is 0")
+func (s *Stack[T]) Second() T {
+	return s.mustPeek(1)
+}
+
+// PeekN returns the item n places below the top of the stack without
+// removing it. PeekN(0) is the top.
+func (s *Stack[T]) PeekN(n int) (v T, err error) {
+	if n < 0 || n >= len(s.stk) {
+		return v, ErrUnderflow
 	}
-	return s.Stk[len(s.Stk)-1]
+	return s.stk[len(s.stk)-1-n], nil
+}
+
+func (s *Stack[T]) mustPeek(n int) T {
+	v, err := s.PeekN(n)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Pick pushes a copy of the item n places below the top of the stack, like
+// Forth's PICK. Pick(0) duplicates the top.
+func (s *Stack[T]) Pick(n int) error {
+	v, err := s.PeekN(n)
+	if err != nil {
+		return err
+	}
+	return s.PushE(v)
+}
+
+// Roll moves the item n places below the top of the stack to the top, like
+// Forth's ROLL. Roll(1) swaps the top two items.
+func (s *Stack[T]) Roll(n int) error {
+	v, err := s.PeekN(n)
+	if err != nil {
+		return err
+	}
+	i := len(s.stk) - 1 - n
+	copy(s.stk[i:], s.stk[i+1:])
+	s.stk[len(s.stk)-1] = v
+	return nil
+}
+
+// Rot moves the third item to the top of the stack.
+func (s *Stack[T]) Rot() error {
+	return s.Roll(2)
 }
 
-func (s *Stack) Second() int {
-	return s.Stk[len(s.Stk)-2]
+// Items returns a copy of the stack, bottom first.
+func (s *Stack[T]) Items() []T {
+	items := make([]T, len(s.stk))
+	copy(items, s.stk)
+	return items
 }
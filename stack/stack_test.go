@@ -0,0 +1,134 @@
+package stack
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestPushPop(t *testing.T) {
+	var s Stack[int]
+	s.Push(1)
+	s.Push(2)
+	s.Push(3)
+	if s.Len() != 3 {
+		t.Fatalf("wrong length. expected=%d, got=%d", 3, s.Len())
+	}
+	if s.Top() != 3 || s.Second() != 2 {
+		t.Fatalf("wrong top two. expected=3 2, got=%d %d", s.Top(), s.Second())
+	}
+	for _, expected := range []int{3, 2, 1} {
+		if got := s.Pop(); got != expected {
+			t.Fatalf("wrong pop. expected=%d, got=%d", expected, got)
+		}
+	}
+	if s.Len() != 0 {
+		t.Fatalf("stack not empty: %v", s.Items())
+	}
+}
+
+func TestUnderflow(t *testing.T) {
+	var s Stack[string]
+	if _, err := s.PopE(); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("PopE: expected ErrUnderflow, got %v", err)
+	}
+	s.Push("a")
+	if _, err := s.PeekN(1); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("PeekN: expected ErrUnderflow, got %v", err)
+	}
+	if _, err := s.PeekN(-1); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("PeekN(-1): expected ErrUnderflow, got %v", err)
+	}
+	if err := s.Rot(); !errors.Is(err, ErrUnderflow) {
+		t.Fatalf("Rot: expected ErrUnderflow, got %v", err)
+	}
+	if !slices.Equal(s.Items(), []string{"a"}) {
+		t.Fatalf("failed operations changed the stack: %v", s.Items())
+	}
+}
+
+func TestPanics(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       func(s *Stack[int])
+		expected error
+	}{
+		{"Pop", func(s *Stack[int]) { s.Pop() }, ErrUnderflow},
+		{"Top", func(s *Stack[int]) { s.Top() }, ErrUnderflow},
+		{"Second", func(s *Stack[int]) { s.Push(1); s.Second() }, ErrUnderflow},
+		{"Push", func(s *Stack[int]) { s.Max = 1; s.Push(1); s.Push(2) }, ErrOverflow},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r != tc.expected {
+					t.Fatalf("expected panic with %v, got %v", tc.expected, r)
+				}
+			}()
+			tc.op(&Stack[int]{})
+		})
+	}
+}
+
+func TestMax(t *testing.T) {
+	s := Stack[int]{Max: 2}
+	if err := s.PushE(1); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.PushE(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := s.PushE(3); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("expected ErrOverflow, got %v", err)
+	}
+	if err := s.Pick(0); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Pick: expected ErrOverflow, got %v", err)
+	}
+	if !slices.Equal(s.Items(), []int{1, 2}) {
+		t.Fatalf("wrong stack. expected=%v, got=%v", []int{1, 2}, s.Items())
+	}
+}
+
+func TestShuffles(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       func(s *Stack[int]) error
+		expected []int
+	}{
+		{"PeekN", func(s *Stack[int]) error {
+			v, err := s.PeekN(2)
+			s.Push(v)
+			return err
+		}, []int{1, 2, 3, 4, 2}},
+		{"Pick 0", func(s *Stack[int]) error { return s.Pick(0) }, []int{1, 2, 3, 4, 4}},
+		{"Pick 3", func(s *Stack[int]) error { return s.Pick(3) }, []int{1, 2, 3, 4, 1}},
+		{"Roll 0", func(s *Stack[int]) error { return s.Roll(0) }, []int{1, 2, 3, 4}},
+		{"Roll 1", func(s *Stack[int]) error { return s.Roll(1) }, []int{1, 2, 4, 3}},
+		{"Roll 3", func(s *Stack[int]) error { return s.Roll(3) }, []int{2, 3, 4, 1}},
+		{"Rot", func(s *Stack[int]) error { return s.Rot() }, []int{1, 3, 4, 2}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var s Stack[int]
+			for _, v := range []int{1, 2, 3, 4} {
+				s.Push(v)
+			}
+			if err := tc.op(&s); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(s.Items(), tc.expected) {
+				t.Fatalf("wrong stack. expected=%v, got=%v", tc.expected, s.Items())
+			}
+		})
+	}
+}
+
+func TestItemsIsACopy(t *testing.T) {
+	var s Stack[int]
+	s.Push(1)
+	items := s.Items()
+	items[0] = 99
+	if s.Top() != 1 {
+		t.Fatalf("modifying Items changed the stack")
+	}
+}
@@ -1,5 +1,7 @@
 package word
 
+import "strings"
+
 type WordType int
 
 type Word struct {
@@ -15,9 +17,21 @@ const (
 	NOTEQ
 	LT
 	GT
+	LE
+	GE
+	ULT
+	UGT
+	ZEROEQ
+	ZEROLT
+	ZEROGT
+	ZERONOTEQ
+	WITHIN
 	AND
 	OR
-	INVERT // 8
+	XOR
+	INVERT
+	LSHIFT
+	RSHIFT // 20
 
 	// Stack
 	INT
@@ -27,30 +41,165 @@ const (
 	SWAP
 	OVER
 	SPIN
+	ROT
+	PICK
+	ROLL
 	EMIT
-	CR // 17
+	CR // 32
 
 	// Math Operations
 	ADD
 	SUBTRACT
 	MULTIPLY
 	DIVIDE
-	MOD // 22
+	MOD
+	SLASHMOD
+	STARSLASH
+	STARSLASHMOD
+	NEGATE
+	ABS
+	MIN
+	MAX
+	ONEPLUS
+	ONEMINUS
+	TWOSTAR
+	TWOSLASH
+	FMMOD
+	SMREM // 50
+
+	// Double-cell numbers
+	DOUBLE
+	DPLUS
+	DMINUS
+	DNEGATE
+	DABS
+	DDOT
+	DDOTR
+	DLT
+	DEQ
+	MSTAR
+	UMSTAR
+	UMSLASHMOD
+	STOD
+	DTOS // 64
+
+	// Floating point
+	FLOAT
+	FPLUS
+	FMINUS
+	FSTAR
+	FSLASH
+	FDOT
+	FDUP
+	FDROP
+	FSWAP
+	FLT
+	FSQRT
+	FSIN
+	FCOS
+	FLOOR
+	FROUND
+	STOF
+	FTOS // 81
 
 	// Conditionals
 	IF
 	ELSE
-	THEN // 25
+	THEN // 84
+
+	// Loops
+	BEGIN
+	UNTIL
+	AGAIN
+	WHILE
+	REPEAT // 89
 
 	// UDF
 	UDF
 	DEFINE
-	SEMICOLON // 28
+	SEMICOLON // 92
+
+	// Compiler
+	IMMEDIATE
+	LBRACKET
+	RBRACKET
+	LITERAL
+	POSTPONE
+	STATE
+	RECURSE
+	EXIT // 100
+
+	// Output
+	SPACE
+	SPACES
+	TYPE
+	STRING
+	DOTQUOTE
+	CSTRING
+	COUNT // 107
+
+	// Input
+	KEY
+	KEYQ
+	ACCEPT
+	REFILL
+	SOURCE // 112
+
+	// Memory
+	HERE
+	ALLOT
+	FETCH
+	STORE
+	COMMA
+	CELLS
+	CREATE
+	VARIABLE // 120
+
+	// Execution tokens
+	TICK
+	BRACKETTICK
+	EXECUTE
+	COMPILECOMMA // 124
+
+	// Deferred words
+	DEFER
+	IS
+	ACTIONOF
+	DEFERFETCH
+	DEFERSTORE // 129
+
+	// Introspection
+	WORDS
+	SEE
+	FIND
+	SEARCHWORDLIST
+	FORTHWORDLIST
+	FORGET
+	MARKER // 136
+
+	// Search order
+	WORDLIST
+	GETORDER
+	SETORDER
+	GETCURRENT
+	SETCURRENT
+	ALSO
+	ONLY
+	PREVIOUS
+	DEFINITIONS
+	FORTH // 146
+
+	// Source files
+	INCLUDE
+	INCLUDED
+	REQUIRE
+	REQUIRED
+	EVALUATE // 151
 
 	// extra
 	NEWLINE
 	EOF
-	ILLEGAL // 31
+	ILLEGAL // 154
 )
 
 var Table = map[string]WordType{
@@ -61,31 +210,160 @@ var Table = map[string]WordType{
 	".":      POP,
 	"%":      MOD,
 	"mod":    MOD,
+	"/mod":   SLASHMOD,
+	"*/":     STARSLASH,
+	"*/mod":  STARSLASHMOD,
+	"negate": NEGATE,
+	"abs":    ABS,
+	"min":    MIN,
+	"max":    MAX,
+	"1+":     ONEPLUS,
+	"1-":     ONEMINUS,
+	"2*":     TWOSTAR,
+	"2/":     TWOSLASH,
+	"fm/mod": FMMOD,
+	"sm/rem": SMREM,
+
+	"d+":      DPLUS,
+	"d-":      DMINUS,
+	"dnegate": DNEGATE,
+	"dabs":    DABS,
+	"d.":      DDOT,
+	"d.r":     DDOTR,
+	"d<":      DLT,
+	"d=":      DEQ,
+	"m*":      MSTAR,
+	"um*":     UMSTAR,
+	"um/mod":  UMSLASHMOD,
+	"s>d":     STOD,
+	"d>s":     DTOS,
+
+	"f+":     FPLUS,
+	"f-":     FMINUS,
+	"f*":     FSTAR,
+	"f/":     FSLASH,
+	"f.":     FDOT,
+	"fdup":   FDUP,
+	"fdrop":  FDROP,
+	"fswap":  FSWAP,
+	"f<":     FLT,
+	"fsqrt":  FSQRT,
+	"fsin":   FSIN,
+	"fcos":   FCOS,
+	"floor":  FLOOR,
+	"fround": FROUND,
+	"s>f":    STOF,
+	"f>s":    FTOS,
+
 	"dup":    DUP,
 	"drop":   DROP,
 	"swap":   SWAP,
 	"over":   OVER,
 	"spin":   SPIN,
+	"rot":    ROT,
+	"pick":   PICK,
+	"roll":   ROLL,
 	"emit":   EMIT,
 	"cr":     CR,
+	"space":  SPACE,
+	"spaces": SPACES,
+	"type":   TYPE,
+	"key":    KEY,
+	"key?":   KEYQ,
+	"accept": ACCEPT,
+	"refill": REFILL,
+	"source": SOURCE,
+	"here":   HERE,
+	"allot":  ALLOT,
 	"true":   TRUE,
 	"false":  FALSE,
 	"=":      EQ,
 	"<":      LT,
 	">":      GT,
-	"!=":     NOTEQ,
+	"<>":     NOTEQ,
+	"<=":     LE,
+	">=":     GE,
+	"u<":     ULT,
+	"u>":     UGT,
+	"0=":     ZEROEQ,
+	"0<":     ZEROLT,
+	"0>":     ZEROGT,
+	"0<>":    ZERONOTEQ,
+	"within": WITHIN,
 	"and":    AND,
 	"or":     OR,
+	"xor":    XOR,
 	"invert": INVERT,
+	"lshift": LSHIFT,
+	"rshift": RSHIFT,
 	":":      DEFINE,
 	";":      SEMICOLON,
 	"if":     IF,
 	"else":   ELSE,
 	"then":   THEN,
+	"begin":  BEGIN,
+	"until":  UNTIL,
+	"again":  AGAIN,
+	"while":  WHILE,
+	"repeat": REPEAT,
+
+	"immediate": IMMEDIATE,
+	"[":         LBRACKET,
+	"]":         RBRACKET,
+	"literal":   LITERAL,
+	"postpone":  POSTPONE,
+	"state":     STATE,
+	"recurse":   RECURSE,
+	"exit":      EXIT,
+
+	"@":        FETCH,
+	"!":        STORE,
+	",":        COMMA,
+	"cells":    CELLS,
+	"create":   CREATE,
+	"variable": VARIABLE,
+
+	"'":        TICK,
+	"[']":      BRACKETTICK,
+	"execute":  EXECUTE,
+	"compile,": COMPILECOMMA,
+
+	"defer":     DEFER,
+	"is":        IS,
+	"action-of": ACTIONOF,
+	"defer@":    DEFERFETCH,
+	"defer!":    DEFERSTORE,
+
+	"count":           COUNT,
+	"words":           WORDS,
+	"see":             SEE,
+	"find":            FIND,
+	"search-wordlist": SEARCHWORDLIST,
+	"forth-wordlist":  FORTHWORDLIST,
+	"forget":          FORGET,
+	"marker":          MARKER,
+
+	"wordlist":    WORDLIST,
+	"get-order":   GETORDER,
+	"set-order":   SETORDER,
+	"get-current": GETCURRENT,
+	"set-current": SETCURRENT,
+	"also":        ALSO,
+	"only":        ONLY,
+	"previous":    PREVIOUS,
+	"definitions": DEFINITIONS,
+	"forth":       FORTH,
+	"include":     INCLUDE,
+	"included":    INCLUDED,
+	"require":     REQUIRE,
+	"required":    REQUIRED,
+	"evaluate":    EVALUATE,
 }
 
+// GetWordType classifies s as a built-in word, ignoring case, or as a word
+// defined in dictionary.
 func GetWordType(s string, dictionary map[Word][]Word) WordType {
-	if wT, ok := Table[s]; ok {
+	if wT, ok := Table[strings.ToLower(s)]; ok {
 		return wT
 	} else if _, ok := dictionary[Word{Type: UDF, Literal: s}]; ok {
 		return UDF